@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// A handshake field compared by -compare, with its value from each server
+type fieldDiff struct {
+	Name string
+	A, B string
+}
+
+// Differs is true when the two servers sent different values for the field
+func (d fieldDiff) Differs() bool {
+	return d.A != d.B
+}
+
+// Fields -compare ignores, connection IDs and auth data change on every connection to the same server
+var compareOptions = mysqlscan.EqualOptions{IgnoreConnectionId: true, IgnoreAuthData: true}
+
+// Compare the handshake fields that should match across a consistently configured fleet, the same fields
+// MySQLv10.EqualWithOptions compares with compareOptions so the diff shows why the servers aren't equal
+func compareHandshakes(a, b *mysqlscan.MySQLv10) []fieldDiff {
+	field := func(name string, value func(*mysqlscan.MySQLv10) string) fieldDiff {
+		return fieldDiff{Name: name, A: value(a), B: value(b)}
+	}
+
+	return []fieldDiff{
+		field("ProtocolVersion", func(s *mysqlscan.MySQLv10) string { return fmt.Sprint(s.ProtocolVersion) }),
+		field("ServerVersion", func(s *mysqlscan.MySQLv10) string { return s.ServerVersion }),
+		field("CharacterSet", func(s *mysqlscan.MySQLv10) string { return s.CharacterSetName() }),
+		field("Status", func(s *mysqlscan.MySQLv10) string { return fmt.Sprintf("%#04x", s.Status) }),
+		capabilitiesDiff(a.Capabilities, b.Capabilities),
+		field("AuthPlugin", func(s *mysqlscan.MySQLv10) string { return s.AuthPlugin }),
+		field("AuthDataLen", func(s *mysqlscan.MySQLv10) string { return fmt.Sprint(s.AuthDataLen) }),
+		field("BannerOnly", func(s *mysqlscan.MySQLv10) string { return fmt.Sprint(s.BannerOnly) }),
+		field("ForcedDecode", func(s *mysqlscan.MySQLv10) string { return fmt.Sprint(s.ForcedDecode) }),
+	}
+}
+
+// Capabilities in hex like the text output, when they differ each server's also names the flags only it sets
+func capabilitiesDiff(a, b uint32) fieldDiff {
+	value := func(caps, other uint32) string {
+		if only := caps &^ other; only != 0 {
+			return fmt.Sprintf("%#08x(%s)", caps, mysqlscan.CapabilityNames(only))
+		}
+		return fmt.Sprintf("%#08x", caps)
+	}
+
+	return fieldDiff{Name: "Capabilities", A: value(a, b), B: value(b, a)}
+}
+
+// Write the comparison as a unified diff from hostA to hostB
+func writeComparison(w io.Writer, hostA, hostB string, diffs []fieldDiff) {
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", hostA, hostB)
+
+	for _, diff := range diffs {
+		if !diff.Differs() {
+			fmt.Fprintf(w, " %s:%s\n", diff.Name, diff.A)
+			continue
+		}
+		fmt.Fprintf(w, "-%s:%s\n+%s:%s\n", diff.Name, diff.A, diff.Name, diff.B)
+	}
+}
+
+// Detect MySQL on both hosts and write the diff of their handshakes to w, returning the exit code
+// Cancelling ctx aborts whichever host is being detected
+func compare(ctx context.Context, w, stderr io.Writer, hostA, hostB string, opts scanOptions) int {
+	a, err := detectWithRetry(ctx, hostA, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return detectExitCode(err)
+	}
+	b, err := detectWithRetry(ctx, hostB, opts)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s\n", err)
+		return detectExitCode(err)
+	}
+
+	writeComparison(w, hostA, hostB, compareHandshakes(a, b))
+	if !a.EqualWithOptions(b, compareOptions) {
+		return exitCheckFailed
+	}
+	return exitDetected
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+func TestCompareHandshakes(t *testing.T) {
+	// Same length plugin name so the packet length doesn't change
+	native := append([]byte{}, normalHandshake...)
+	copy(native[len(native)-22:], "mysql_native_password")
+
+	var a, b mysqlscan.MySQLv10
+	if err := a.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if err := b.Decode(native); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	var differs []string
+	for _, diff := range compareHandshakes(&a, &b) {
+		if diff.Differs() {
+			differs = append(differs, diff.Name)
+		}
+	}
+	if len(differs) != 1 || differs[0] != "AuthPlugin" {
+		t.Errorf("Expected only AuthPlugin to differ: %v", differs)
+	}
+
+	var out bytes.Buffer
+	writeComparison(&out, "a:3306", "b:3306", compareHandshakes(&a, &b))
+	for _, line := range []string{"--- a:3306\n+++ b:3306\n", "-AuthPlugin:caching_sha2_password\n+AuthPlugin:mysql_native_password\n", " ServerVersion:8.0.21\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Comparison missing '%s':\n%s", line, out.String())
+		}
+	}
+
+	// Capabilities are hex, with the flags only one server sets named on its side
+	noSSL := a
+	noSSL.Capabilities &^= mysqlscan.ClientSSL
+	out.Reset()
+	writeComparison(&out, "a:3306", "b:3306", compareHandshakes(&a, &noSSL))
+	expected := fmt.Sprintf("-Capabilities:%#08x(CLIENT_SSL)\n+Capabilities:%#08x\n", a.Capabilities, noSSL.Capabilities)
+	if !strings.Contains(out.String(), expected) {
+		t.Errorf("Comparison missing '%s':\n%s", expected, out.String())
+	}
+	if diff := compareHandshakes(&a, &a)[4]; diff.A != fmt.Sprintf("%#08x", a.Capabilities) {
+		t.Errorf("Expected matching capabilities as just hex: %s", diff.A)
+	}
+
+	// The diff agrees with EqualWithOptions on whether the servers match, whichever field differs
+	status, connection := a, a
+	status.Status = 0x0000
+	connection.ConnectionId, connection.AuthData = 99, []byte("different scramble..")
+	for _, other := range []*mysqlscan.MySQLv10{&a, &b, &status, &connection, &noSSL} {
+		differs := false
+		for _, diff := range compareHandshakes(&a, other) {
+			differs = differs || diff.Differs()
+		}
+		if equal := a.EqualWithOptions(other, compareOptions); equal == differs {
+			t.Errorf("Diff and EqualWithOptions disagree, equal %t: %v", equal, compareHandshakes(&a, other))
+		}
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	defer func(compare []string, output string) { scanCompare, scanOutput = compare, output }(scanCompare, scanOutput)
+
+	native := append([]byte{}, normalHandshake...)
+	copy(native[len(native)-22:], "mysql_native_password")
+	host := serveHandshake(t, normalHandshake)
+
+	tests := []struct {
+		name    string
+		compare []string
+		code    int
+	}{
+		{"same", []string{host, serveHandshake(t, normalHandshake)}, exitDetected},
+		{"different", []string{host, serveHandshake(t, native)}, exitCheckFailed},
+		{"refused", []string{host, closedPort(t)}, exitConnectFailed},
+	}
+
+	for _, test := range tests {
+		scanCompare = test.compare
+		scanOutput = filepath.Join(t.TempDir(), "diff")
+		if code := run(io.Discard); code != test.code {
+			t.Errorf("%s: exit code didn't match expected %d: %d", test.name, test.code, code)
+		}
+	}
+}
+
+func TestCompareCancelled(t *testing.T) {
+	// A cancelled ctx stops compare before it waits out the silent host's timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	code := compare(ctx, io.Discard, io.Discard, serveSilent(t), serveHandshake(t, normalHandshake), scanOptions{timeout: 10 * time.Second})
+	// The silent host accepted the connection, so like any failed read it isn't MySQL
+	if code != exitNotMySQL {
+		t.Errorf("Expected exit code %d for the cancelled host: %d", exitNotMySQL, code)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Compare ignored its context, took %s", elapsed)
+	}
+}
@@ -0,0 +1,33 @@
+package mysqlscan
+
+// Classifications returned by AuthPluginSecurity
+const (
+	AuthPluginStrong   = "strong"
+	AuthPluginOK       = "ok"
+	AuthPluginInsecure = "insecure"
+	AuthPluginUnknown  = "unknown"
+)
+
+// Names of the auth plugins AuthPluginSecurity knows about
+const (
+	authPluginCachingSHA2    = "caching_sha2_password"
+	authPluginNativePassword = "mysql_native_password"
+	authPluginOldPassword    = "mysql_old_password"
+)
+
+// AuthPluginSecurity classifies the default auth plugin the server offers
+// caching_sha2_password is strong, mysql_native_password is ok though its stored hash is only a double SHA1,
+// and mysql_old_password is insecure since the pre-4.1 hash can be cracked from a captured scramble
+// Anything else is unknown
+func (s *MySQLv10) AuthPluginSecurity() string {
+	switch s.AuthPlugin {
+	case authPluginCachingSHA2:
+		return AuthPluginStrong
+	case authPluginNativePassword:
+		return AuthPluginOK
+	case authPluginOldPassword:
+		return AuthPluginInsecure
+	}
+
+	return AuthPluginUnknown
+}
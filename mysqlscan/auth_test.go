@@ -0,0 +1,47 @@
+package mysqlscan
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAuthPluginSecurity(t *testing.T) {
+	tests := []struct {
+		plugin   string
+		security string
+	}{
+		{plugin: "caching_sha2_password", security: AuthPluginStrong},
+		{plugin: "mysql_native_password", security: AuthPluginOK},
+		{plugin: "mysql_old_password", security: AuthPluginInsecure},
+		{plugin: "auth_socket", security: AuthPluginUnknown},
+	}
+
+	for _, test := range tests {
+		// Re-encode the capture advertising the plugin
+		sql := MySQLv10{}
+		if err := sql.Decode(normalHandshake); err != nil {
+			t.Fatalf("Failed to decode handshake: %s", err)
+		}
+		sql.AuthPlugin = test.plugin
+		buf, err := sql.Encode()
+		if err != nil {
+			t.Fatalf("Failed to encode handshake '%s': %s", test.plugin, err)
+		}
+
+		sql = MySQLv10{}
+		if err := sql.Decode(buf); err != nil {
+			t.Fatalf("Failed to decode handshake '%s': %s", test.plugin, err)
+		}
+		if security := sql.AuthPluginSecurity(); security != test.security {
+			t.Errorf("Security didn't match expected '%s': %s", test.plugin, security)
+		}
+		if !strings.Contains(sql.String(), "AuthPluginSecurity:"+test.security) {
+			t.Errorf("String() didn't include the security '%s': %s", test.plugin, sql.String())
+		}
+
+		warned := strings.Contains(sql.String(), "mysql_old_password, the pre-4.1 hash is insecure")
+		if warned != (test.security == AuthPluginInsecure) {
+			t.Errorf("Insecure plugin warning didn't match expected '%s': %s", test.plugin, sql.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"io"
+)
+
+// DetectMySQLBanner on the given host, only reading and decoding the protocol version and server version
+// This returns as soon as the server version's null terminator arrives, skipping the capabilities and
+// auth data for scans that only need to know something MySQL like is listening
+// A nil dialer connects directly the same as DetectMySQLContext
+func DetectMySQLBanner(ctx context.Context, dialer ContextDialer, host string) (*MySQLv10, error) {
+	return detect(ctx, dialer, host, readBanner, decodeBanner)
+}
+
+// DetectMySQLBannerOptions is DetectMySQLBanner reading no more than opts.MaxBytes
+// The banner is never decoded with another protocol version so AcceptAnyProtocolVersion doesn't apply
+func DetectMySQLBannerOptions(ctx context.Context, dialer ContextDialer, host string, opts DecodeOptions) (*MySQLv10, error) {
+	return detect(ctx, dialer, host, func(r io.Reader) ([]byte, error) {
+		return readBannerSize(r, cmp.Or(opts.MaxBytes, maxBannerSize))
+	}, decodeBanner)
+}
+
+// Largest banner DetectMySQLBanner will read, the server version is right at the start of the packet
+const maxBannerSize = 1024
+
+// Read from r until the server version is terminated, or the whole packet is here for short
+// packets such as an ERR packet
+func readBanner(r io.Reader) ([]byte, error) {
+	return readBannerSize(r, maxBannerSize)
+}
+
+// Same as readBanner but reading no more than size bytes
+func readBannerSize(r io.Reader, size int) ([]byte, error) {
+	return readPacket(r, size, func(buf []byte) bool {
+		return packetComplete(buf) || (len(buf) > 5 && bytes.IndexByte(buf[5:], 0) != -1)
+	})
+}
+
+func decodeBanner(buf []byte) (*MySQLv10, error) {
+	sql := MySQLv10{}
+	if err := sql.DecodeBanner(buf); err != nil {
+		return nil, err
+	}
+
+	return &sql, nil
+}
+
+// DecodeBanner decodes only the protocol_version and server_version at the start of a v9 or v10 handshake
+// buf doesn't need to hold the rest of the packet, BannerOnly is set and every other field is left zero
+func (s *MySQLv10) DecodeBanner(buf []byte) error {
+	// Start from nothing like Decode does, a full handshake decoded into s before mustn't show through
+	*s = MySQLv10{}
+
+	if len(buf) < 5 {
+		return ErrorMissingData
+	}
+	if buf[4] == errPacketHeader {
+		return decodeErrPacket(buf)
+	}
+	if buf[4] != 9 && buf[4] != 10 {
+		if isXProtocol(buf) {
+			return ErrorXProtocol
+		}
+		return ErrorNotMySQL
+	}
+	if buf[3] != 0 {
+		return ErrorUnexpectedSequence
+	}
+
+	// The server version can't run past the end of the packet, even if more of the buffer is there
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+	if pktLen+4 < len(buf) {
+		buf = buf[:pktLen+4]
+	}
+
+	// server_version(null terminated string)
+	end := bytes.IndexByte(buf[5:], 0)
+	if end == -1 {
+		return ErrorMissingData
+	}
+	s.ProtocolVersion = buf[4]
+	s.ServerVersion = string(buf[5 : 5+end])
+	s.BannerOnly = true
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDecodeBanner(t *testing.T) {
+	// Only the header, protocol version and server version are needed
+	for _, buf := range [][]byte{normalHandshake, normalHandshake[:12]} {
+		sql := MySQLv10{}
+		if err := sql.DecodeBanner(buf); err != nil {
+			t.Fatalf("Failed to decode banner from %d bytes: %s", len(buf), err)
+		}
+		if sql.ProtocolVersion != 10 || sql.ServerVersion != "8.0.21" || !sql.BannerOnly {
+			t.Errorf("Banner didn't match expected: %+v", sql)
+		}
+		if sql.Capabilities != 0 || sql.AuthData != nil || sql.AuthPlugin != "" {
+			t.Errorf("Banner decoded more than the server version: %+v", sql)
+		}
+	}
+
+	// Decoded into a struct that held a full handshake, only the banner is left
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if err := sql.DecodeBanner(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode banner: %s", err)
+	}
+	if !reflect.DeepEqual(sql, MySQLv10{ProtocolVersion: 10, ServerVersion: "8.0.21", BannerOnly: true}) {
+		t.Errorf("Fields from the full handshake were kept: %s", sql.String())
+	}
+
+	if err := (&MySQLv10{}).DecodeBanner(normalHandshake[:10]); err != ErrorMissingData {
+		t.Errorf("Expected ErrorMissingData for an unterminated version, got: %v", err)
+	}
+	if err := (&MySQLv10{}).DecodeBanner([]byte("SSH-2.0-OpenSSH_8.9\r\n")); !errors.Is(err, ErrorNotMySQL) {
+		t.Errorf("Expected ErrorNotMySQL for an SSH banner, got: %v", err)
+	}
+}
+
+func TestDetectMySQLBanner(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	// Send only the start of the handshake and hold the connection open, a full read would time out
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(normalHandshake[:20])
+		time.Sleep(2 * time.Second)
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sql, err := DetectMySQLBanner(ctx, nil, ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL banner: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" || sql.Capabilities != 0 {
+		t.Errorf("Banner didn't match expected: %+v", sql)
+	}
+}
+
+func TestDetectMySQLBannerReset(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	// Reset partway through the server version, closing with no linger sends a reset instead of a FIN
+	// The pause lets the dial finish first, an early reset fails the connect instead
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(normalHandshake[:8])
+		time.Sleep(50 * time.Millisecond)
+		conn.(*net.TCPConn).SetLinger(0)
+		conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = DetectMySQLBanner(ctx, nil, ln.Addr().String())
+	if !errors.Is(err, ErrorConnectionReset) || !errors.Is(err, ErrorRead) {
+		t.Errorf("Expected ErrorConnectionReset, got: %v", err)
+	}
+}
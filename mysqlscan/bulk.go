@@ -0,0 +1,163 @@
+package mysqlscan
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// DefaultScanTimeout is how long BulkScan gives each attempt at a host when ScanOptions.Timeout is zero
+const DefaultScanTimeout = time.Second
+
+// DefaultRetryBackoff is how long BulkScan waits before the first retry of a host when ScanOptions.Backoff is zero
+const DefaultRetryBackoff = 250 * time.Millisecond
+
+// ScanOptions for how BulkScan scans each host
+type ScanOptions struct {
+	// Concurrency is the number of hosts scanned at once, less than 1 scans one at a time
+	Concurrency int
+
+	// Timeout for each attempt at a host, covering the dial and reading the handshake, zero is DefaultScanTimeout
+	// Only used without Detect, which bounds its own attempts
+	Timeout time.Duration
+
+	// Retries is how many more attempts a host gets after a connect or read error, with exponential backoff
+	// A reset during the handshake is retried straight away
+	Retries int
+
+	// Backoff is the delay before the first retry of a host, doubled for every retry after that,
+	// zero is DefaultRetryBackoff
+	Backoff time.Duration
+
+	// Rate is the most connections per second across every worker, retries included, 0 is unlimited
+	Rate float64
+
+	// Jitter is the most random delay added before each connection, so the timing doesn't look like a scanner
+	Jitter time.Duration
+
+	// Detect is called for each attempt at a host in place of DetectMySQLContext bounded by Timeout, for
+	// scanning through a proxy or with DecodeOptions. Its error decides whether the host is retried
+	Detect func(ctx context.Context, host string) (*MySQLv10, error)
+}
+
+// BulkScan detects MySQL on each of targets concurrently with a pool of workers, sending each host's result
+// on the returned channel in whatever order the hosts finish
+// The channel is closed once every target has been scanned. Cancelling ctx stops new hosts from
+// being started and aborts the ones in progress, whose results are still sent
+func BulkScan(ctx context.Context, targets []string, opts ScanOptions) <-chan ScanResult {
+	jobs := make(chan string)
+	go func() {
+		defer close(jobs)
+		for _, host := range targets {
+			select {
+			case jobs <- host:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return BulkScanChannel(ctx, jobs, opts)
+}
+
+// BulkScanChannel is BulkScan for targets that arrive over time, such as lines read from a pipe
+// The returned channel is closed once targets is closed and every host sent on it has been scanned,
+// or once ctx is done and the hosts in progress are sent, without waiting for targets to be closed
+func BulkScanChannel(ctx context.Context, targets <-chan string, opts ScanOptions) <-chan ScanResult {
+	limiter := newScanLimiter(opts)
+	results := make(chan ScanResult)
+	var wg sync.WaitGroup
+	for range max(opts.Concurrency, 1) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var host string
+				var more bool
+				select {
+				case host, more = <-targets:
+				case <-ctx.Done():
+				}
+				if !more || ctx.Err() != nil {
+					return
+				}
+
+				start := time.Now()
+				sql, err := scanHost(ctx, host, opts, limiter)
+				results <- ScanResult{Host: host, MySQL: sql, Err: err, Duration: time.Since(start)}
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	return results
+}
+
+// ScanHost detects MySQL on a single host the way BulkScan does, with the retries and rate limit from opts
+func ScanHost(ctx context.Context, host string, opts ScanOptions) (*MySQLv10, error) {
+	return scanHost(ctx, host, opts, newScanLimiter(opts))
+}
+
+// Limiter for the Rate and Jitter of opts, nil when neither is set
+func newScanLimiter(opts ScanOptions) *rateLimiter {
+	if opts.Rate <= 0 && opts.Jitter <= 0 {
+		return nil
+	}
+
+	return newRateLimiter(opts.Rate, opts.Jitter)
+}
+
+// Detect MySQL on host, retrying with exponential backoff when connecting or reading fails
+// Anything that got as far as decoding, including ErrorNotMySQL, is a definite answer and isn't retried
+// A reset during the handshake is retried straight away without the backoff, still counting as a retry
+// Cancelling ctx aborts the attempt and any backoff. Attempts and retries are logged to slog.Default at info level
+func scanHost(ctx context.Context, host string, opts ScanOptions, limiter *rateLimiter) (*MySQLv10, error) {
+	detect := opts.Detect
+	if detect == nil {
+		detect = func(ctx context.Context, host string) (*MySQLv10, error) {
+			ctx, cancel := context.WithTimeout(ctx, cmp.Or(opts.Timeout, DefaultScanTimeout))
+			defer cancel()
+			return DetectMySQLContext(ctx, host)
+		}
+	}
+
+	backoff := cmp.Or(opts.Backoff, DefaultRetryBackoff)
+	for attempt := 0; ; attempt++ {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, fmt.Errorf("Failed to detect MySQL, scan of '%s' cancelled: %w", host, err)
+		}
+		slog.Info("Scanning host", "host", host, "attempt", attempt+1)
+		sql, err := detect(ctx, host)
+		if err == nil {
+			slog.Info("Detected MySQL", "host", host, "server_version", sql.ServerVersion)
+			return sql, nil
+		}
+		if attempt >= opts.Retries || ctx.Err() != nil || (!errors.Is(err, ErrorConnect) && !errors.Is(err, ErrorRead)) {
+			slog.Info("MySQL not detected", "host", host, "error", err)
+			return sql, err
+		}
+
+		// A reset during the handshake is a balancer dropping the first connection of a flow rather than an
+		// overloaded host, the next connection works so it's retried straight away
+		if errors.Is(err, ErrorConnectionReset) {
+			slog.Info("Retrying host after a reset", "host", host, "error", err)
+			continue
+		}
+
+		slog.Info("Retrying host", "host", host, "error", err, "backoff", backoff)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff *= 2
+	}
+}
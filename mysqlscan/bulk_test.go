@@ -0,0 +1,83 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBulkScan(t *testing.T) {
+	caps := uint32(ClientProtocol41 | ClientSecureConnection | ClientPluginAuth)
+	first, stopFirst := StartFakeServer("8.0.36", caps)
+	defer stopFirst()
+	second, stopSecond := StartFakeServer("5.7.44", caps)
+	defer stopSecond()
+
+	// Nothing is listening once the listener is closed
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	closed := ln.Addr().String()
+	ln.Close()
+
+	versions := map[string]string{}
+	var errs []error
+	for result := range BulkScan(context.Background(), []string{first, second, closed}, ScanOptions{Concurrency: 2, Timeout: time.Second, Rate: 100}) {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+			continue
+		}
+		versions[result.Host] = result.MySQL.ServerVersion
+	}
+	if len(versions) != 2 || versions[first] != "8.0.36" || versions[second] != "5.7.44" {
+		t.Errorf("Expected both fake servers detected, got %v", versions)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], ErrorConnect) {
+		t.Errorf("Expected ErrorConnect for the closed port, got %v", errs)
+	}
+
+	// A rate too high to have an interval between connections is unlimited rather than a panic
+	for result := range BulkScan(context.Background(), []string{first}, ScanOptions{Timeout: time.Second, Rate: 1e12}) {
+		if result.Err != nil {
+			t.Errorf("Failed to detect MySQL at an unlimited rate: %s", result.Err)
+		}
+	}
+
+	// Cancelled before starting, nothing is scanned and the channel is still closed
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for result := range BulkScan(ctx, []string{first, second}, ScanOptions{}) {
+		if result.Err == nil {
+			t.Errorf("Expected no host to be detected after cancelling, got %s", result.Host)
+		}
+	}
+}
+
+func TestScanHostRetries(t *testing.T) {
+	// Fails to connect twice then works, like a host dropping connections when busy
+	attempts := 0
+	opts := ScanOptions{Retries: 2, Backoff: time.Millisecond, Detect: func(ctx context.Context, host string) (*MySQLv10, error) {
+		attempts++
+		if attempts <= 2 {
+			return nil, ErrorConnect
+		}
+		return &MySQLv10{ServerVersion: "8.0.36"}, nil
+	}}
+	sql, err := ScanHost(context.Background(), "10.0.0.1:3306", opts)
+	if err != nil || sql.ServerVersion != "8.0.36" || attempts != 3 {
+		t.Errorf("Expected the third attempt to detect MySQL, got %d attempts: %v", attempts, err)
+	}
+
+	// Not MySQL is a definite answer so the retries aren't used
+	attempts = 0
+	opts.Detect = func(ctx context.Context, host string) (*MySQLv10, error) {
+		attempts++
+		return nil, ErrorNotMySQL
+	}
+	if _, err := ScanHost(context.Background(), "10.0.0.1:3306", opts); !errors.Is(err, ErrorNotMySQL) || attempts != 1 {
+		t.Errorf("Expected a single attempt returning ErrorNotMySQL, got %d attempts: %v", attempts, err)
+	}
+}
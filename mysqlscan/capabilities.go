@@ -0,0 +1,201 @@
+package mysqlscan
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Capability flags from the handshake packet, named after the CLIENT_* flags described here:
+// https://dev.mysql.com/doc/internals/en/capability-flags.html#packet-Protocol::CapabilityFlags
+// Test them against MySQLv10.Capabilities, or use CapabilityFlags to get them all as booleans
+const (
+	// Lower two bytes, sent as capability_flags_1
+	ClientLongPassword     = 0x00000001 // CLIENT_LONG_PASSWORD
+	ClientFoundRows        = 0x00000002 // CLIENT_FOUND_ROWS
+	ClientLongFlag         = 0x00000004 // CLIENT_LONG_FLAG
+	ClientConnectWithDB    = 0x00000008 // CLIENT_CONNECT_WITH_DB
+	ClientNoSchema         = 0x00000010 // CLIENT_NO_SCHEMA
+	ClientCompress         = 0x00000020 // CLIENT_COMPRESS
+	ClientODBC             = 0x00000040 // CLIENT_ODBC
+	ClientLocalFiles       = 0x00000080 // CLIENT_LOCAL_FILES
+	ClientIgnoreSpace      = 0x00000100 // CLIENT_IGNORE_SPACE
+	ClientProtocol41       = 0x00000200 // CLIENT_PROTOCOL_41
+	ClientInteractive      = 0x00000400 // CLIENT_INTERACTIVE
+	ClientSSL              = 0x00000800 // CLIENT_SSL
+	ClientIgnoreSigpipe    = 0x00001000 // CLIENT_IGNORE_SIGPIPE
+	ClientTransactions     = 0x00002000 // CLIENT_TRANSACTIONS
+	ClientReserved         = 0x00004000 // CLIENT_RESERVED
+	ClientSecureConnection = 0x00008000 // CLIENT_SECURE_CONNECTION
+
+	// Upper two bytes, sent as capability_flags_2 in the extended fields
+	ClientMultiStatements            = 0x00010000 // CLIENT_MULTI_STATEMENTS
+	ClientMultiResults               = 0x00020000 // CLIENT_MULTI_RESULTS
+	ClientPSMultiResults             = 0x00040000 // CLIENT_PS_MULTI_RESULTS
+	ClientPluginAuth                 = 0x00080000 // CLIENT_PLUGIN_AUTH
+	ClientConnectAttrs               = 0x00100000 // CLIENT_CONNECT_ATTRS
+	ClientPluginAuthLenencClientData = 0x00200000 // CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA
+	ClientCanHandleExpiredPasswords  = 0x00400000 // CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS
+	ClientSessionTrack               = 0x00800000 // CLIENT_SESSION_TRACK
+	ClientDeprecateEOF               = 0x01000000 // CLIENT_DEPRECATE_EOF
+	ClientSSLVerifyServerCert        = 0x40000000 // CLIENT_SSL_VERIFY_SERVER_CERT
+	ClientRememberOptions            = 0x80000000 // CLIENT_REMEMBER_OPTIONS
+)
+
+// Names of each capability flag as they appear in the capability flags doc, in bit order
+var capabilityFlagNames = []struct {
+	flag uint32
+	name string
+}{
+	{ClientLongPassword, "CLIENT_LONG_PASSWORD"},
+	{ClientFoundRows, "CLIENT_FOUND_ROWS"},
+	{ClientLongFlag, "CLIENT_LONG_FLAG"},
+	{ClientConnectWithDB, "CLIENT_CONNECT_WITH_DB"},
+	{ClientNoSchema, "CLIENT_NO_SCHEMA"},
+	{ClientCompress, "CLIENT_COMPRESS"},
+	{ClientODBC, "CLIENT_ODBC"},
+	{ClientLocalFiles, "CLIENT_LOCAL_FILES"},
+	{ClientIgnoreSpace, "CLIENT_IGNORE_SPACE"},
+	{ClientProtocol41, "CLIENT_PROTOCOL_41"},
+	{ClientInteractive, "CLIENT_INTERACTIVE"},
+	{ClientSSL, "CLIENT_SSL"},
+	{ClientIgnoreSigpipe, "CLIENT_IGNORE_SIGPIPE"},
+	{ClientTransactions, "CLIENT_TRANSACTIONS"},
+	{ClientReserved, "CLIENT_RESERVED"},
+	{ClientSecureConnection, "CLIENT_SECURE_CONNECTION"},
+	{ClientMultiStatements, "CLIENT_MULTI_STATEMENTS"},
+	{ClientMultiResults, "CLIENT_MULTI_RESULTS"},
+	{ClientPSMultiResults, "CLIENT_PS_MULTI_RESULTS"},
+	{ClientPluginAuth, "CLIENT_PLUGIN_AUTH"},
+	{ClientConnectAttrs, "CLIENT_CONNECT_ATTRS"},
+	{ClientPluginAuthLenencClientData, "CLIENT_PLUGIN_AUTH_LENENC_CLIENT_DATA"},
+	{ClientCanHandleExpiredPasswords, "CLIENT_CAN_HANDLE_EXPIRED_PASSWORDS"},
+	{ClientSessionTrack, "CLIENT_SESSION_TRACK"},
+	{ClientDeprecateEOF, "CLIENT_DEPRECATE_EOF"},
+	{ClientSSLVerifyServerCert, "CLIENT_SSL_VERIFY_SERVER_CERT"},
+	{ClientRememberOptions, "CLIENT_REMEMBER_OPTIONS"},
+}
+
+// CapabilityNames is the pipe separated names of the capability flags set in flags, such as CLIENT_PROTOCOL_41
+// Set bits without a name are added as a single hex value at the end so none go unreported
+func CapabilityNames(flags uint32) string {
+	var names []string
+	unknown := flags
+	for _, f := range capabilityFlagNames {
+		if flags&f.flag != 0 {
+			names = append(names, f.name)
+			unknown &^= f.flag
+		}
+	}
+	if unknown != 0 {
+		names = append(names, fmt.Sprintf("%#08x", unknown))
+	}
+
+	return strings.Join(names, "|")
+}
+
+// CapabilitySet is the capability bit-field broken out into named booleans
+// Each field is named after its CLIENT_* flag in the capability flags doc
+type CapabilitySet struct {
+	LongPassword               bool
+	FoundRows                  bool
+	LongFlag                   bool
+	ConnectWithDB              bool
+	NoSchema                   bool
+	Compress                   bool
+	ODBC                       bool
+	LocalFiles                 bool
+	IgnoreSpace                bool
+	Protocol41                 bool
+	Interactive                bool
+	SSL                        bool
+	IgnoreSigpipe              bool
+	Transactions               bool
+	Reserved                   bool
+	SecureConnection           bool
+	MultiStatements            bool
+	MultiResults               bool
+	PSMultiResults             bool
+	PluginAuth                 bool
+	ConnectAttrs               bool
+	PluginAuthLenencClientData bool
+	CanHandleExpiredPasswords  bool
+	SessionTrack               bool
+	DeprecateEOF               bool
+	SSLVerifyServerCert        bool
+	RememberOptions            bool
+}
+
+// CapabilityFlags decodes the raw Capabilities bit-field into a CapabilitySet
+func (s *MySQLv10) CapabilityFlags() CapabilitySet {
+	has := func(flag uint32) bool {
+		return s.Capabilities&flag != 0
+	}
+
+	return CapabilitySet{
+		LongPassword:               has(ClientLongPassword),
+		FoundRows:                  has(ClientFoundRows),
+		LongFlag:                   has(ClientLongFlag),
+		ConnectWithDB:              has(ClientConnectWithDB),
+		NoSchema:                   has(ClientNoSchema),
+		Compress:                   has(ClientCompress),
+		ODBC:                       has(ClientODBC),
+		LocalFiles:                 has(ClientLocalFiles),
+		IgnoreSpace:                has(ClientIgnoreSpace),
+		Protocol41:                 has(ClientProtocol41),
+		Interactive:                has(ClientInteractive),
+		SSL:                        has(ClientSSL),
+		IgnoreSigpipe:              has(ClientIgnoreSigpipe),
+		Transactions:               has(ClientTransactions),
+		Reserved:                   has(ClientReserved),
+		SecureConnection:           has(ClientSecureConnection),
+		MultiStatements:            has(ClientMultiStatements),
+		MultiResults:               has(ClientMultiResults),
+		PSMultiResults:             has(ClientPSMultiResults),
+		PluginAuth:                 has(ClientPluginAuth),
+		ConnectAttrs:               has(ClientConnectAttrs),
+		PluginAuthLenencClientData: has(ClientPluginAuthLenencClientData),
+		CanHandleExpiredPasswords:  has(ClientCanHandleExpiredPasswords),
+		SessionTrack:               has(ClientSessionTrack),
+		DeprecateEOF:               has(ClientDeprecateEOF),
+		SSLVerifyServerCert:        has(ClientSSLVerifyServerCert),
+		RememberOptions:            has(ClientRememberOptions),
+	}
+}
+
+// SupportsTLS is true when the server advertises CLIENT_SSL, meaning a client can upgrade the connection to TLS
+func (s *MySQLv10) SupportsTLS() bool {
+	return s.Capabilities&ClientSSL != 0
+}
+
+// DeprecateEOF is true when the server advertises CLIENT_DEPRECATE_EOF, sending OK packets where older
+// servers sent EOF packets, so connectors that only understand EOF packets need the flag left unset
+func (s *MySQLv10) DeprecateEOF() bool {
+	return s.Capabilities&ClientDeprecateEOF != 0
+}
+
+// Likely TLS postures returned by TLSPosture
+const (
+	// TLSAdvertised means CLIENT_SSL is set alongside CLIENT_SECURE_CONNECTION, a modern server offering TLS
+	TLSAdvertised = "advertised"
+
+	// TLSOptional means CLIENT_SSL is set without CLIENT_SECURE_CONNECTION, TLS is offered but the server
+	// still speaks the pre-4.1 auth so clients are likely to connect without it
+	TLSOptional = "optional"
+
+	// TLSNone means CLIENT_SSL isn't set so the connection can't be upgraded to TLS
+	TLSNone = "none"
+)
+
+// TLSPosture infers how the server treats TLS from its capability flags
+// The handshake can't say whether TLS is required, that is only enforced after the client responds, so
+// this is a hint to prioritise servers rather than a guarantee
+func (s *MySQLv10) TLSPosture() string {
+	switch {
+	case !s.SupportsTLS():
+		return TLSNone
+	case s.Capabilities&ClientSecureConnection == 0:
+		return TLSOptional
+	}
+
+	return TLSAdvertised
+}
@@ -0,0 +1,56 @@
+package mysqlscan
+
+import (
+	"fmt"
+)
+
+// Names of the common collation IDs sent as character_set in the handshake
+// The full table can be listed on a server with: SELECT id, collation_name FROM information_schema.collations
+var collationNames = map[uint8]string{
+	1:   "big5_chinese_ci",
+	2:   "latin2_czech_cs",
+	3:   "dec8_swedish_ci",
+	4:   "cp850_general_ci",
+	5:   "latin1_german1_ci",
+	6:   "hp8_english_ci",
+	7:   "koi8r_general_ci",
+	8:   "latin1_swedish_ci",
+	9:   "latin2_general_ci",
+	10:  "swe7_swedish_ci",
+	11:  "ascii_general_ci",
+	12:  "ujis_japanese_ci",
+	13:  "sjis_japanese_ci",
+	14:  "cp1251_bulgarian_ci",
+	15:  "latin1_danish_ci",
+	16:  "hebrew_general_ci",
+	18:  "tis620_thai_ci",
+	19:  "euckr_korean_ci",
+	24:  "gb2312_chinese_ci",
+	25:  "greek_general_ci",
+	26:  "cp1250_general_ci",
+	28:  "gbk_chinese_ci",
+	30:  "latin5_turkish_ci",
+	31:  "latin1_german2_ci",
+	33:  "utf8_general_ci",
+	35:  "ucs2_general_ci",
+	45:  "utf8mb4_general_ci",
+	46:  "utf8mb4_bin",
+	47:  "latin1_bin",
+	48:  "latin1_general_ci",
+	49:  "latin1_general_cs",
+	63:  "binary",
+	83:  "utf8_bin",
+	192: "utf8_unicode_ci",
+	224: "utf8mb4_unicode_ci",
+	248: "gb18030_chinese_ci",
+	255: "utf8mb4_0900_ai_ci",
+}
+
+// CharacterSetName of the default collation, or unknown(ID) if it isn't in the table
+func (s *MySQLv10) CharacterSetName() string {
+	if name, ok := collationNames[s.CharacterSet]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("unknown(%d)", s.CharacterSet)
+}
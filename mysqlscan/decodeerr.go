@@ -0,0 +1,25 @@
+package mysqlscan
+
+import "fmt"
+
+// DecodeError is returned by MySQLv10.Decode when the packet's structure is wrong, saying which field
+// decoding failed at. Err is the reason, ErrorMissingData, ErrorInvalidProtocol or ErrorUnexpectedSequence,
+// so errors.Is still matches those
+type DecodeError struct {
+	// Offset in the packet, header included, where Field starts
+	Offset int
+
+	// Field is the name of the field in the handshake doc, such as auth_plugin_data_part_1, the same
+	// names as DecodeStep.Field
+	Field string
+
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s, decoding %s at offset %d", e.Err, e.Field, e.Offset)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
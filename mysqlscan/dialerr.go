@@ -0,0 +1,55 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+)
+
+// Categories of DialError
+const (
+	// DialRefused means the host is up but nothing is listening on the port
+	DialRefused = "refused"
+
+	// DialTimeout means nothing answered before the deadline, the host may be down or filtered by a firewall
+	DialTimeout = "timeout"
+
+	// DialNoRoute means the network or host is unreachable from here
+	DialNoRoute = "no route"
+
+	// DialOther is anything else, such as a DNS failure or a cancelled scan
+	DialOther = "other"
+)
+
+// DialError is wrapped by ErrorConnect with why the connection couldn't be made
+// Use errors.As to get at the Category, e.g. to count refused ports separately from timeouts
+type DialError struct {
+	Category string
+	Err      error
+}
+
+func (e *DialError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// Categorize an error from dialing, looking through the *net.OpError and *os.SyscallError wrapping
+func newDialError(err error) *DialError {
+	category := DialOther
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		category = DialRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		category = DialTimeout
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		category = DialNoRoute
+	}
+
+	return &DialError{Category: category, Err: err}
+}
@@ -0,0 +1,81 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// ContextDialer from a function, for dialers that fail in a particular way
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+func (f dialFunc) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return f(ctx, network, address)
+}
+
+func TestDialErrorCategory(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	refused := ln.Addr().String()
+	ln.Close()
+
+	tests := []struct {
+		name     string
+		dialer   ContextDialer
+		host     string
+		category string
+	}{
+		{
+			name:     "Closed port",
+			host:     refused,
+			category: DialRefused,
+		},
+		{
+			// Unroutable addresses never answer, so the dial blocks until the deadline
+			name: "Unroutable address",
+			dialer: dialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				<-ctx.Done()
+				return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+			}),
+			host:     "10.255.255.1:3306",
+			category: DialTimeout,
+		},
+		{
+			name: "No route",
+			dialer: dialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}
+			}),
+			host:     "10.255.255.1:3306",
+			category: DialNoRoute,
+		},
+		{
+			name: "DNS failure",
+			dialer: dialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: &net.DNSError{Err: "no such host", Name: "db.invalid", IsNotFound: true}}
+			}),
+			host:     "db.invalid:3306",
+			category: DialOther,
+		},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		_, err := DetectMySQLDialer(ctx, test.dialer, test.host)
+		cancel()
+
+		var dialErr *DialError
+		if !errors.Is(err, ErrorConnect) || !errors.As(err, &dialErr) {
+			t.Errorf("Expected DialError wrapped by ErrorConnect '%s', got: %v", test.name, err)
+			continue
+		}
+		if dialErr.Category != test.category {
+			t.Errorf("Category didn't match expected '%s': %s (%v)", test.name, dialErr.Category, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package mysqlscan
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+var ErrorAuthDataLength = errors.New("AuthData length can't be encoded with the given capability flags")
+
+// Encode the handshake into a wire format v10 packet, the inverse of Decode
+// Decoding the result gives back an equal MySQLv10, apart from Latency which isn't part of the packet
+// and fields like Protocol41 and AuthDataLen that Decode derives from the capability flags and AuthData
+//
+// The capability flags decide how AuthData is split, so its length has to match what Decode expects:
+// 8 bytes without ClientSecureConnection, otherwise 8 bytes plus a part 2 of at least 12 bytes
+// (exactly 12 bytes without ClientPluginAuth since there is no length to say otherwise)
+func (s *MySQLv10) Encode() ([]byte, error) {
+	if s.ProtocolVersion != 0 && s.ProtocolVersion != 10 {
+		return nil, ErrorInvalidProtocol
+	}
+	if strings.IndexByte(s.ServerVersion, 0) != -1 || strings.IndexByte(s.AuthPlugin, 0) != -1 {
+		return nil, errors.New("ServerVersion and AuthPlugin can't contain a null byte")
+	}
+
+	secure := s.Capabilities&ClientSecureConnection != 0
+	pluginAuth := s.Capabilities&ClientPluginAuth != 0
+	switch {
+	case !secure && len(s.AuthData) != 8:
+		return nil, ErrorAuthDataLength
+	case secure && len(s.AuthData) < 8+12:
+		return nil, ErrorAuthDataLength
+	case secure && !pluginAuth && len(s.AuthData) != 8+12:
+		return nil, ErrorAuthDataLength
+	case len(s.AuthData)+1 > 0xff:
+		return nil, ErrorAuthDataLength
+	}
+
+	// Header is filled in once the payload length is known
+	buf := make([]byte, 4, 128)
+
+	// protocol_version(1) server_version(null terminated string) connection_id(4)
+	buf = append(buf, 10)
+	buf = append(buf, s.ServerVersion...)
+	buf = append(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, s.ConnectionId)
+
+	// auth_plugin_data_1(8) filler_1(1) capability_flag_1(2)
+	buf = append(buf, s.AuthData[:8]...)
+	buf = append(buf, 0)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(s.Capabilities))
+
+	// character_set(1) status_flags(2) capability_flags_2(2)
+	buf = append(buf, s.CharacterSet)
+	buf = binary.LittleEndian.AppendUint16(buf, s.Status)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(s.Capabilities>>16))
+
+	// auth_data_plugin_len(1) is the whole auth data length including the null terminator
+	authLen := byte(0)
+	if pluginAuth {
+		authLen = byte(len(s.AuthData) + 1)
+	}
+	buf = append(buf, authLen)
+
+	// reserved(10)
+	buf = append(buf, make([]byte, 10)...)
+
+	if secure {
+		// auth_plugin_data_part_2 followed by its null terminator
+		buf = append(buf, s.AuthData[8:]...)
+		buf = append(buf, 0)
+	}
+
+	if pluginAuth {
+		// auth_plugin_name(null terminated string)
+		buf = append(buf, s.AuthPlugin...)
+		buf = append(buf, 0)
+	}
+
+	pktLen := len(buf) - 4
+	if pktLen > 0xffffff {
+		return nil, errors.New("Handshake is too large for a single packet")
+	}
+	buf[0], buf[1], buf[2] = byte(pktLen), byte(pktLen>>8), byte(pktLen>>16)
+	buf[3] = 0 // sequence
+
+	return buf, nil
+}
@@ -0,0 +1,119 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRoundTrip(t *testing.T) {
+	scramble := []byte("abcdefghijklmnopqrst")
+
+	tests := []struct {
+		name string
+		sql  MySQLv10
+	}{
+		{
+			name: "Plugin auth and secure connection",
+			sql: MySQLv10{
+				ProtocolVersion: 10,
+				ServerVersion:   "8.0.32",
+				ConnectionId:    1234,
+				CharacterSet:    255,
+				Status:          serverStatusAutocommit,
+				Capabilities:    ClientProtocol41 | ClientSecureConnection | ClientPluginAuth | ClientDeprecateEOF,
+				Protocol41:      true,
+				AuthPlugin:      "caching_sha2_password",
+				AuthData:        scramble,
+			},
+		},
+		{
+			name: "Plugin auth with a long scramble",
+			sql: MySQLv10{
+				ProtocolVersion: 10,
+				ServerVersion:   "8.0.32",
+				Capabilities:    ClientProtocol41 | ClientSecureConnection | ClientPluginAuth,
+				Protocol41:      true,
+				AuthPlugin:      "mysql_native_password",
+				AuthData:        append(append([]byte{}, scramble...), "uvwxyz"...),
+			},
+		},
+		{
+			name: "Secure connection without plugin auth",
+			sql: MySQLv10{
+				ProtocolVersion: 10,
+				ServerVersion:   "5.1.73",
+				ConnectionId:    5,
+				CharacterSet:    8,
+				Capabilities:    ClientProtocol41 | ClientSecureConnection,
+				Protocol41:      true,
+				AuthData:        scramble,
+			},
+		},
+		{
+			name: "Neither plugin auth nor secure connection",
+			sql: MySQLv10{
+				ProtocolVersion: 10,
+				ServerVersion:   "4.0.30",
+				ConnectionId:    3,
+				Capabilities:    ClientLongPassword,
+				AuthData:        scramble[:8],
+			},
+		},
+	}
+
+	for _, test := range tests {
+		buf, err := test.sql.Encode()
+		if err != nil {
+			t.Errorf("Failed to encode '%s': %s", test.name, err)
+			continue
+		}
+
+		decoded := MySQLv10{}
+		if err := decoded.Decode(buf); err != nil {
+			t.Errorf("Failed to decode encoded handshake '%s': %s", test.name, err)
+			continue
+		}
+		test.sql.RawPacket = buf
+		test.sql.AuthDataPart1 = test.sql.AuthData[:8]
+		if len(test.sql.AuthData) > 8 {
+			test.sql.AuthDataPart2 = test.sql.AuthData[8:]
+		}
+		if test.sql.Capabilities&ClientPluginAuth != 0 {
+			test.sql.AuthDataLen = uint8(len(test.sql.AuthData) + 1)
+		}
+		test.sql.CachingSHA2 = test.sql.AuthPlugin == "caching_sha2_password"
+		if !reflect.DeepEqual(decoded, test.sql) {
+			t.Errorf("Round-trip didn't match '%s'\ngot:  %+v\nwant: %+v", test.name, decoded, test.sql)
+		}
+	}
+}
+
+func TestEncodePacketCapture(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	buf, err := sql.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode handshake: %s", err)
+	}
+	if !bytes.Equal(buf, normalHandshake) {
+		t.Errorf("Encoded handshake didn't match the packet capture\ngot:  %x\nwant: %x", buf, normalHandshake)
+	}
+}
+
+func TestEncodeAuthDataLength(t *testing.T) {
+	tests := []MySQLv10{
+		{Capabilities: 0, AuthData: make([]byte, 20)},
+		{Capabilities: ClientSecureConnection, AuthData: make([]byte, 8)},
+		{Capabilities: ClientSecureConnection, AuthData: make([]byte, 21)},
+	}
+
+	for _, sql := range tests {
+		if _, err := sql.Encode(); err != ErrorAuthDataLength {
+			t.Errorf("Expected ErrorAuthDataLength for %d bytes with capabilities %#x, got: %v", len(sql.AuthData), sql.Capabilities, err)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package mysqlscan
+
+import "time"
+
+// A release series such as 5.7 and the date it stopped getting updates
+type releaseSeries struct {
+	major int
+	minor int
+	eol   time.Time
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// End of life dates for each release series, oldest first
+// MySQL dates are from Oracle's lifetime support policy, Percona Server follows the MySQL series it's built on
+// Innovation releases such as 8.1 and 9.0, like MariaDB's short term releases, reach end of life as the next
+// release comes out
+// https://www.oracle.com/us/support/library/lifetime-support-technology-069183.pdf
+// https://mariadb.org/about/#maintenance-policy
+var endOfLifeSeries = map[string][]releaseSeries{
+	FlavorMySQL: {
+		{5, 0, date(2012, time.January, 9)},
+		{5, 1, date(2013, time.December, 31)},
+		{5, 5, date(2018, time.December, 31)},
+		{5, 6, date(2021, time.February, 28)},
+		{5, 7, date(2023, time.October, 31)},
+		{8, 0, date(2026, time.April, 30)},
+		{8, 1, date(2023, time.October, 25)},
+		{8, 2, date(2024, time.January, 16)},
+		{8, 3, date(2024, time.April, 30)},
+		{8, 4, date(2032, time.April, 30)},
+		{9, 0, date(2024, time.October, 15)},
+		{9, 1, date(2025, time.January, 21)},
+		{9, 2, date(2025, time.April, 15)},
+		{9, 3, date(2025, time.July, 22)},
+	},
+	FlavorMariaDB: {
+		{5, 5, date(2020, time.April, 11)},
+		{10, 0, date(2019, time.March, 31)},
+		{10, 1, date(2020, time.October, 17)},
+		{10, 2, date(2022, time.May, 23)},
+		{10, 3, date(2023, time.May, 25)},
+		{10, 4, date(2024, time.June, 18)},
+		{10, 5, date(2025, time.June, 24)},
+		{10, 6, date(2026, time.July, 6)},
+		{10, 7, date(2023, time.February, 9)},
+		{10, 8, date(2023, time.May, 20)},
+		{10, 9, date(2023, time.August, 22)},
+		{10, 10, date(2023, time.November, 17)},
+		{10, 11, date(2028, time.February, 16)},
+		{11, 0, date(2024, time.June, 6)},
+		{11, 1, date(2024, time.August, 21)},
+		{11, 2, date(2024, time.November, 21)},
+		{11, 3, date(2024, time.May, 29)},
+		{11, 4, date(2029, time.May, 29)},
+	},
+}
+
+// EndOfLife is the date the server's release series stopped getting updates
+// Versions older than every series in the table get the oldest date since they were end of life by then,
+// ok is false for unknown flavors, unparsable versions and series newer than the table knows about
+func (s *MySQLv10) EndOfLife() (eol time.Time, ok bool) {
+	flavor := s.Flavor()
+	if flavor == FlavorPercona {
+		flavor = FlavorMySQL
+	}
+	table := endOfLifeSeries[flavor]
+	if len(table) == 0 {
+		return time.Time{}, false
+	}
+
+	version, err := s.Version()
+	if err != nil {
+		return time.Time{}, false
+	}
+	for _, series := range table {
+		if series.major == version.Major && series.minor == version.Minor {
+			return series.eol, true
+		}
+	}
+	if oldest := table[0]; version.Major < oldest.major || (version.Major == oldest.major && version.Minor < oldest.minor) {
+		return oldest.eol, true
+	}
+
+	return time.Time{}, false
+}
+
+// IsEndOfLife reports whether the server's release series has reached end of life, see EndOfLife
+func (s *MySQLv10) IsEndOfLife() bool {
+	return s.IsEndOfLifeAt(time.Now())
+}
+
+// IsEndOfLifeAt reports whether the server's release series had reached end of life by at
+func (s *MySQLv10) IsEndOfLifeAt(at time.Time) bool {
+	eol, ok := s.EndOfLife()
+	return ok && at.After(eol)
+}
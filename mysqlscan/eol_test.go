@@ -0,0 +1,49 @@
+package mysqlscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEndOfLife(t *testing.T) {
+	// Pinned so the table doesn't start failing as series reach end of life
+	at := date(2025, time.June, 1)
+
+	tests := []struct {
+		version string
+		known   bool
+		eol     bool
+	}{
+		{version: "5.6.51", known: true, eol: true},
+		{version: "5.7.44-log", known: true, eol: true},
+		{version: "8.0.21", known: true, eol: false},
+		{version: "8.4.3", known: true, eol: false},
+		{version: "4.1.22", known: true, eol: true},
+		{version: "9.1.0", known: true, eol: true},
+		{version: "9.9.0", known: false, eol: false},
+		{version: "5.7.30-33", known: true, eol: true},
+		{version: "5.5.5-10.4.27-MariaDB", known: true, eol: true},
+		{version: "10.11.6-MariaDB", known: true, eol: false},
+		{version: "10.8.8-MariaDB", known: true, eol: true},
+		{version: "11.2.6-MariaDB", known: true, eol: true},
+		{version: "11.4.4-MariaDB", known: true, eol: false},
+		{version: "5.5.65-MariaDB", known: true, eol: true},
+		{version: "unknown", known: false, eol: false},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{ServerVersion: test.version}
+		if _, known := sql.EndOfLife(); known != test.known {
+			t.Errorf("Expected known %t for '%s'", test.known, test.version)
+		}
+		if eol := sql.IsEndOfLifeAt(at); eol != test.eol {
+			t.Errorf("Expected end of life %t for '%s'", test.eol, test.version)
+		}
+	}
+
+	// 8.0 went end of life in April 2026
+	sql := MySQLv10{ServerVersion: "8.0.21"}
+	if !sql.IsEndOfLifeAt(date(2026, time.May, 1)) {
+		t.Errorf("Expected 8.0 to be end of life after April 2026")
+	}
+}
@@ -0,0 +1,48 @@
+package mysqlscan
+
+import "bytes"
+
+// EqualOptions relaxes which fields MySQLv10.EqualWithOptions compares
+type EqualOptions struct {
+	// IgnoreConnectionId skips ConnectionId, it's different on every connection to the same server
+	IgnoreConnectionId bool
+
+	// IgnoreAuthData skips AuthData, the scramble is random for every connection
+	IgnoreAuthData bool
+}
+
+// Equal is true when other is the same handshake, see EqualWithOptions
+func (s *MySQLv10) Equal(other *MySQLv10) bool {
+	return s.EqualWithOptions(other, EqualOptions{})
+}
+
+// EqualWithOptions is true when other has the same ProtocolVersion, ServerVersion, CharacterSet, Status,
+// Capabilities, AuthPlugin, AuthDataLen, BannerOnly and ForcedDecode, and the same ConnectionId and AuthData
+// unless opts ignores them. Every other field is left out:
+// CachingSHA2, Protocol41, AuthDataPart1, AuthDataPart2 and WeakScramble are worked out from AuthPlugin,
+// Capabilities and AuthData, so they only differ when those do or when AuthData is ignored
+// RawPacket and Extra are the bytes as received, which differ with the connection id and scramble even
+// between two connections to the same server
+// Suspicious and NonZeroReserved flag oddities in the packet's layout rather than how the server is set up
+// Latency and TLS describe the connection rather than the handshake
+func (s *MySQLv10) EqualWithOptions(other *MySQLv10, opts EqualOptions) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if !opts.IgnoreConnectionId && s.ConnectionId != other.ConnectionId {
+		return false
+	}
+	if !opts.IgnoreAuthData && !bytes.Equal(s.AuthData, other.AuthData) {
+		return false
+	}
+
+	return s.ProtocolVersion == other.ProtocolVersion &&
+		s.ServerVersion == other.ServerVersion &&
+		s.CharacterSet == other.CharacterSet &&
+		s.Status == other.Status &&
+		s.Capabilities == other.Capabilities &&
+		s.AuthPlugin == other.AuthPlugin &&
+		s.AuthDataLen == other.AuthDataLen &&
+		s.BannerOnly == other.BannerOnly &&
+		s.ForcedDecode == other.ForcedDecode
+}
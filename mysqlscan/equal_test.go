@@ -0,0 +1,53 @@
+package mysqlscan
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	a := MySQLv10{}
+	if err := a.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	// Same server on another connection, only the connection id and scramble change
+	b := MySQLv10{}
+	if err := b.Decode(patchHandshake(12, 0x11)); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !a.Equal(&a) {
+		t.Errorf("Handshake isn't equal to itself")
+	}
+	if a.Equal(&b) {
+		t.Errorf("Handshakes with different connection ids compared equal")
+	}
+	if !a.EqualWithOptions(&b, EqualOptions{IgnoreConnectionId: true}) {
+		t.Errorf("Handshakes with different connection ids weren't equal ignoring connection ids")
+	}
+
+	c := MySQLv10{}
+	if err := c.Decode(patchHandshake(16, 0x01)); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if a.Equal(&c) || !a.EqualWithOptions(&c, EqualOptions{IgnoreAuthData: true}) {
+		t.Errorf("Auth data wasn't compared or ignored as expected")
+	}
+
+	// Ignoring the per connection fields still catches a different server
+	d := MySQLv10{}
+	if err := d.Decode(patchHandshake(27, 0x08)); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if a.EqualWithOptions(&d, EqualOptions{IgnoreConnectionId: true, IgnoreAuthData: true}) {
+		t.Errorf("Handshakes with different character sets compared equal")
+	}
+
+	// Latency isn't part of the handshake
+	e := a
+	e.Latency = 5
+	if !a.Equal(&e) {
+		t.Errorf("Latency was compared")
+	}
+
+	if a.Equal(nil) || !(*MySQLv10)(nil).Equal(nil) {
+		t.Errorf("Nil handshakes weren't compared as expected")
+	}
+}
@@ -0,0 +1,72 @@
+package mysqlscan
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// First payload byte of an ERR packet, in place of the protocol_version of a handshake
+const errPacketHeader = 0xff
+
+// ErrPacket is an ERR packet the server sent instead of a handshake
+// Servers do this when they refuse the connection outright, most often host based access control such as
+// 1130 "Host 'x' is not allowed to connect to this MySQL server" or 1040 "Too many connections"
+// This packet is described here:
+// https://dev.mysql.com/doc/internals/en/packet-ERR_Packet.html
+type ErrPacket struct {
+	// Code is the MySQL error code, e.g. 1130
+	Code uint16
+
+	// SQLState is the 5 character SQL state, only present when the server sent the # marker
+	// Errors sent before the handshake normally don't include it
+	SQLState string
+
+	// Message is the human readable error message
+	Message string
+}
+
+func (e *ErrPacket) Error() string {
+	if e.SQLState != "" {
+		return fmt.Sprintf("MySQL error %d (%s): %s", e.Code, e.SQLState, e.Message)
+	}
+
+	return fmt.Sprintf("MySQL error %d: %s", e.Code, e.Message)
+}
+
+// Decode the ERR packet given the byte slice, a well formed packet is returned as the *ErrPacket error
+// Anything too short to hold the header and error code is ErrorMissingData
+func decodeErrPacket(buf []byte) error {
+	if len(buf) < 4 {
+		return ErrorMissingData
+	}
+
+	// Same packet header as the handshake, 3 bytes length and the sequence byte
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+	if buf[3] != 0 {
+		return ErrorUnexpectedSequence
+	}
+	if pktLen+4 > len(buf) {
+		return ErrorMissingData
+	}
+	buf = buf[:pktLen+4]
+	pos := 4
+
+	// header(1) then error_code(2)
+	if pos+3 > len(buf) || buf[pos] != errPacketHeader {
+		return ErrorMissingData
+	}
+	pos += 1
+	e := &ErrPacket{Code: binary.LittleEndian.Uint16(buf[pos : pos+2])}
+	pos += 2
+
+	// sql_state_marker(1) '#' and sql_state(5) are only sent once the client has said it speaks protocol 41
+	if pos+6 <= len(buf) && buf[pos] == '#' {
+		e.SQLState = string(buf[pos+1 : pos+6])
+		pos += 6
+	}
+
+	// error_message(string EOF)
+	e.Message = string(buf[pos:])
+
+	return e
+}
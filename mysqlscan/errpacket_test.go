@@ -0,0 +1,48 @@
+package mysqlscan
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeErrPacket(t *testing.T) {
+	// Build an ERR packet payload, header 0xff then the error code little endian
+	packet := func(payload string) []byte {
+		return append([]byte{byte(len(payload)), 0x00, 0x00, 0x00}, payload...)
+	}
+
+	tests := []struct {
+		name     string
+		buf      []byte
+		expected ErrPacket
+	}{
+		{
+			name:     "Host not allowed",
+			buf:      packet("\xff\x6a\x04Host '10.0.0.1' is not allowed to connect to this MySQL server"),
+			expected: ErrPacket{Code: 1130, Message: "Host '10.0.0.1' is not allowed to connect to this MySQL server"},
+		},
+		{
+			name:     "SQL state",
+			buf:      packet("\xff\x10\x04#08004Too many connections"),
+			expected: ErrPacket{Code: 1040, SQLState: "08004", Message: "Too many connections"},
+		},
+	}
+
+	for _, test := range tests {
+		err := (&MySQLv10{}).Decode(test.buf)
+
+		var errPacket *ErrPacket
+		if !errors.As(err, &errPacket) {
+			t.Errorf("Expected ErrPacket '%s', got: %v", test.name, err)
+			continue
+		}
+		if *errPacket != test.expected {
+			t.Errorf("ErrPacket didn't match expected '%s'\ngot:  %+v\nwant: %+v", test.name, *errPacket, test.expected)
+		}
+	}
+
+	// Too short to hold an error code
+	if err := (&MySQLv10{}).Decode(packet("\xff\x6a")); err != ErrorMissingData {
+		t.Errorf("Expected ErrorMissingData for truncated ERR packet, got: %v", err)
+	}
+}
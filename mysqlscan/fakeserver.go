@@ -0,0 +1,82 @@
+package mysqlscan
+
+import (
+	"math/rand/v2"
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Connection IDs a fake server hands out start here, high enough that LowConnectionId isn't set
+const fakeServerConnectionId = 1000
+
+// StartFakeServer listens on a localhost port and sends every connection a v10 handshake with the given
+// server version and capability flags, then closes it. It's meant for testing anything that detects MySQL
+// end to end over a real socket, and as a demo server to point the scanner at
+// Each connection gets the next connection id and a fresh 20 byte scramble, or 8 bytes without
+// ClientSecureConnection, with mysql_native_password as the auth plugin when ClientPluginAuth is set
+// Like httptest.NewServer it panics if it can't listen, call stop to close the listener and wait for it to finish
+func StartFakeServer(version string, caps uint32) (addr string, stop func()) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mysqlscan: failed to listen for fake server: " + err.Error())
+	}
+
+	handshake := MySQLv10{
+		ProtocolVersion: 10,
+		ServerVersion:   version,
+		CharacterSet:    255,
+		Status:          serverStatusAutocommit,
+		Capabilities:    caps,
+	}
+	scrambleLen := 8
+	if caps&ClientSecureConnection != 0 {
+		scrambleLen = 20
+	}
+	if caps&ClientPluginAuth != 0 {
+		handshake.AuthPlugin = authPluginNativePassword
+	}
+	// Fail now rather than on the first connection if the handshake can't be encoded
+	handshake.AuthData = fakeScramble(scrambleLen)
+	if _, err := handshake.Encode(); err != nil {
+		ln.Close()
+		panic("mysqlscan: fake server handshake can't be encoded: " + err.Error())
+	}
+
+	var connectionId atomic.Uint32
+	connectionId.Store(fakeServerConnectionId - 1)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			sql := handshake
+			sql.ConnectionId = connectionId.Add(1)
+			sql.AuthData = fakeScramble(scrambleLen)
+			buf, _ := sql.Encode()
+			conn.Write(buf)
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String(), func() {
+		ln.Close()
+		wg.Wait()
+	}
+}
+
+// Random scramble of n bytes, each between 1 and 127 like a real server's so it never contains a null
+func fakeScramble(n int) []byte {
+	scramble := make([]byte, n)
+	for i := range scramble {
+		scramble[i] = byte(1 + rand.IntN(127))
+	}
+
+	return scramble
+}
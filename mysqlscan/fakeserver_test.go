@@ -0,0 +1,41 @@
+package mysqlscan
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartFakeServer(t *testing.T) {
+	caps := uint32(ClientProtocol41 | ClientSecureConnection | ClientPluginAuth | ClientSSL)
+	addr, stop := StartFakeServer("8.0.36", caps)
+	defer stop()
+
+	var connectionIds []uint32
+	for range 2 {
+		sql, err := DetectMySQLTimeout(addr, time.Second)
+		if err != nil {
+			t.Fatalf("Failed to detect the fake server: %s", err)
+		}
+		if sql.ServerVersion != "8.0.36" || sql.Capabilities != caps || sql.AuthPlugin != "mysql_native_password" {
+			t.Errorf("Fake server handshake didn't match expected: %s", sql.String())
+		}
+		if len(sql.AuthData) != 20 || len(sql.Warnings()) != 0 {
+			t.Errorf("Fake server handshake should look like a real server: %s", sql.String())
+		}
+		connectionIds = append(connectionIds, sql.ConnectionId)
+	}
+	if connectionIds[1] != connectionIds[0]+1 {
+		t.Errorf("Expected consecutive connection ids, got %v", connectionIds)
+	}
+
+	// Without CLIENT_SECURE_CONNECTION only the 8 byte part 1 is sent
+	oldAddr, oldStop := StartFakeServer("4.0.30", ClientLongPassword)
+	defer oldStop()
+	sql, err := DetectMySQLTimeout(oldAddr, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to detect the fake server: %s", err)
+	}
+	if sql.ServerVersion != "4.0.30" || len(sql.AuthData) != 8 {
+		t.Errorf("Fake pre-4.1 server handshake didn't match expected: %s", sql.String())
+	}
+}
@@ -0,0 +1,27 @@
+package mysqlscan
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+)
+
+// Length in hex characters of a Fingerprint, 64 bits so a collision across even a large fleet is unlikely
+const fingerprintLength = 16
+
+// Fingerprint hashes the parts of the handshake that come from the server's configuration, the capabilities,
+// character set, auth plugin and status flags, into a short hex string
+// ConnectionId and AuthData change on every connection so are left out, identically configured servers share
+// a fingerprint and can be grouped with sort | uniq -c
+func (s *MySQLv10) Fingerprint() string {
+	var fields [7]byte
+	binary.LittleEndian.PutUint32(fields[0:], s.Capabilities)
+	fields[4] = s.CharacterSet
+	binary.LittleEndian.PutUint16(fields[5:], s.Status)
+
+	h := sha256.New()
+	h.Write(fields[:])
+	h.Write([]byte(s.AuthPlugin))
+
+	return hex.EncodeToString(h.Sum(nil))[:fingerprintLength]
+}
@@ -0,0 +1,50 @@
+package mysqlscan
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	decode := func(buf []byte) *MySQLv10 {
+		t.Helper()
+		sql := &MySQLv10{}
+		if err := sql.Decode(buf); err != nil {
+			t.Fatalf("Failed to decode handshake: %s", err)
+		}
+		return sql
+	}
+
+	normal := decode(normalHandshake)
+
+	// connection_id is at offset 12 and the first part of the auth data at 16, both change every connection
+	other := patchHandshake(12, 0x99, 0x88, 0x77, 0x00, 'a', 'b', 'c', 'd', 'e', 'f', 'g', 'h')
+	reconnected := decode(other)
+	if reconnected.ConnectionId == normal.ConnectionId || string(reconnected.AuthData) == string(normal.AuthData) {
+		t.Fatalf("Patched handshake should have a different connection id and auth data: %s", reconnected.String())
+	}
+	if reconnected.Fingerprint() != normal.Fingerprint() {
+		t.Errorf("Fingerprint changed with only the connection id and auth data: %s != %s", reconnected.Fingerprint(), normal.Fingerprint())
+	}
+
+	// character_set is at offset 27 and is part of the server's configuration
+	latin1 := decode(patchHandshake(27, 0x08))
+	if latin1.Fingerprint() == normal.Fingerprint() {
+		t.Errorf("Fingerprint didn't change with the character set: %s", latin1.Fingerprint())
+	}
+
+	if len(normal.Fingerprint()) != fingerprintLength {
+		t.Errorf("Fingerprint wasn't %d characters: %s", fingerprintLength, normal.Fingerprint())
+	}
+	if !strings.Contains(normal.String(), "Fingerprint:"+normal.Fingerprint()) {
+		t.Errorf("String() didn't include the fingerprint: %s", normal.String())
+	}
+	out, err := json.Marshal(normal)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"fingerprint":"`+normal.Fingerprint()+`"`) {
+		t.Errorf("JSON didn't include the fingerprint: %s", out)
+	}
+}
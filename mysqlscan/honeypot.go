@@ -0,0 +1,65 @@
+package mysqlscan
+
+import "strings"
+
+// Markers in the breakdown from HoneypotMarkers
+const (
+	HoneypotWeakScramble    = "weak_scramble"
+	HoneypotNonZeroReserved = "non_zero_reserved"
+	HoneypotLowConnectionId = "low_connection_id"
+	HoneypotSuspicious      = "suspicious"
+	HoneypotKnownVersion    = "known_version"
+)
+
+// Server versions honeypots send out of the box, matched against the start of the server version
+var honeypotVersions = []string{
+	// OpenCanary's default mysql.banner
+	"5.5.43-0ubuntu0.14.04.1",
+}
+
+// HoneypotMarker is one sign of a honeypot found in the handshake and how many points it adds to the score
+type HoneypotMarker struct {
+	Marker string `json:"marker"`
+	Points int    `json:"points"`
+}
+
+// HoneypotMarkers is the breakdown of HoneypotScore, the markers found in the handshake with their points
+// A replayed scramble is the strongest sign since a real server's is random, a low connection id the weakest
+// since a server that was just restarted has one too
+func (s *MySQLv10) HoneypotMarkers() []HoneypotMarker {
+	var markers []HoneypotMarker
+	add := func(found bool, marker string, points int) {
+		if found {
+			markers = append(markers, HoneypotMarker{marker, points})
+		}
+	}
+
+	add(s.WeakScramble, HoneypotWeakScramble, 40)
+	add(s.NonZeroReserved, HoneypotNonZeroReserved, 30)
+	add(s.Suspicious, HoneypotSuspicious, 20)
+	add(s.LowConnectionId(), HoneypotLowConnectionId, 15)
+	add(s.knownHoneypotVersion(), HoneypotKnownVersion, 30)
+
+	return markers
+}
+
+// HoneypotScore is how confident, from 0 to 100, the handshake came from a honeypot rather than a real server
+// It adds up the points of the HoneypotMarkers, so no marker alone reaches 50 but several score high
+func (s *MySQLv10) HoneypotScore() int {
+	score := 0
+	for _, m := range s.HoneypotMarkers() {
+		score += m.Points
+	}
+
+	return min(score, 100)
+}
+
+func (s *MySQLv10) knownHoneypotVersion() bool {
+	for _, v := range honeypotVersions {
+		if strings.HasPrefix(s.ServerVersion, v) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,41 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestHoneypotScore(t *testing.T) {
+	// Zeroed connection id at 12, a static scramble in both auth data parts at 16 and 43 and a reserved byte set at 33
+	buf := patchHandshake(12, 0, 0, 0, 0)
+	copy(buf[16:24], bytes.Repeat([]byte{'A'}, 8))
+	copy(buf[43:55], bytes.Repeat([]byte{'A'}, 12))
+	buf[33] = 0x01
+
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if score := sql.HoneypotScore(); score < 80 {
+		t.Errorf("Expected a high score with several markers: %d", score)
+	}
+	expected := []HoneypotMarker{{HoneypotWeakScramble, 40}, {HoneypotNonZeroReserved, 30}, {HoneypotLowConnectionId, 15}}
+	if markers := sql.HoneypotMarkers(); !reflect.DeepEqual(markers, expected) {
+		t.Errorf("Markers didn't match expected\ngot:  %v\nwant: %v", markers, expected)
+	}
+
+	// A real server has no markers, even decoded into the struct the marked handshake was
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if score := sql.HoneypotScore(); score != 0 {
+		t.Errorf("Expected a real server to score 0: %d %v", score, sql.HoneypotMarkers())
+	}
+
+	// Every marker together is capped at 100
+	sql = MySQLv10{ServerVersion: "5.5.43-0ubuntu0.14.04.1", WeakScramble: true, NonZeroReserved: true, Suspicious: true}
+	if score := sql.HoneypotScore(); score != 100 {
+		t.Errorf("Expected the score to be capped at 100: %d", score)
+	}
+}
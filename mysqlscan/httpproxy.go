@@ -0,0 +1,94 @@
+package mysqlscan
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+var ErrorHTTPProxy = errors.New("HTTP CONNECT proxy failed")
+
+// HTTPProxyDialer connects to hosts by asking an HTTP proxy to CONNECT a tunnel to them, for networks where
+// an HTTP proxy is the only way out. The MySQL handshake is then read over the tunnel like any other connection
+type HTTPProxyDialer struct {
+	// ProxyAddr is the host:port of the HTTP proxy
+	ProxyAddr string
+
+	// Username and Password are sent as Basic Proxy-Authorization, leave empty for none
+	Username string
+	Password string
+
+	// Forward dials the proxy itself, a nil Forward connects directly
+	Forward ContextDialer
+}
+
+// DialContext connects to the proxy and asks it to CONNECT to address
+func (d *HTTPProxyDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	conn, err := forward.DialContext(ctx, network, d.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The CONNECT request is part of dialing so it shares the dial deadline, and cancelling ctx aborts it
+	// by moving the deadline up to now so a stalled proxy can't hold up an interrupted scan
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	tunnel, err := d.connect(conn, address)
+	if !stop() {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %w", ErrorHTTPProxy, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return tunnel, nil
+}
+
+// Send the CONNECT request for address and check the proxy opened the tunnel
+func (d *HTTPProxyDialer) connect(conn net.Conn, address string) (net.Conn, error) {
+	req := "CONNECT " + address + " HTTP/1.1\r\nHost: " + address + "\r\n"
+	if d.Username != "" {
+		req += "Proxy-Authorization: Basic " + base64.StdEncoding.EncodeToString([]byte(d.Username+":"+d.Password)) + "\r\n"
+	}
+	if _, err := conn.Write([]byte(req + "\r\n")); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorHTTPProxy, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: CONNECT to %s failed with %s", ErrorHTTPProxy, address, resp.Status)
+	}
+
+	// MySQL speaks first, so its handshake may already be buffered behind the proxy's response
+	return &bufferedConn{Conn: conn, r: br}, nil
+}
+
+// Connection that reads whatever was buffered while reading the proxy's response before reading conn
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.r.Read(p)
+}
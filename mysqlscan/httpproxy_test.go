@@ -0,0 +1,120 @@
+package mysqlscan
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Minimal HTTP CONNECT proxy that accepts one connection, records the requested address and then answers
+// with status, followed straight away by packet like MySQL would when the tunnel opens
+func serveHTTPProxy(t *testing.T, status string, packet []byte) (string, <-chan *http.Request) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	requested := make(chan *http.Request, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil {
+			return
+		}
+		requested <- req
+
+		// One write so the handshake arrives in the same read as the response
+		conn.Write(append([]byte("HTTP/1.1 "+status+"\r\n\r\n"), packet...))
+	}()
+
+	return ln.Addr().String(), requested
+}
+
+func TestHTTPProxyDialer(t *testing.T) {
+	proxyAddr, requested := serveHTTPProxy(t, "200 Connection established", normalHandshake)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	dialer := &HTTPProxyDialer{ProxyAddr: proxyAddr, Username: "scanner", Password: "secret"}
+	sql, err := DetectMySQLDialer(ctx, dialer, "db.internal:3306")
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL through the HTTP proxy: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" || sql.AuthPlugin != "caching_sha2_password" {
+		t.Errorf("Tunneled handshake didn't match expected: %s", sql.String())
+	}
+
+	req := <-requested
+	if req.Method != http.MethodConnect || req.Host != "db.internal:3306" {
+		t.Errorf("Proxy was sent '%s %s'", req.Method, req.Host)
+	}
+	if user, pass, ok := parseProxyAuth(req); !ok || user != "scanner" || pass != "secret" {
+		t.Errorf("Proxy-Authorization didn't match the credentials: %s", req.Header.Get("Proxy-Authorization"))
+	}
+}
+
+// Basic credentials from a Proxy-Authorization header, which http.Request only parses from Authorization
+func parseProxyAuth(req *http.Request) (string, string, bool) {
+	r := &http.Request{Header: http.Header{"Authorization": req.Header.Values("Proxy-Authorization")}}
+	return r.BasicAuth()
+}
+
+func TestHTTPProxyDialerRefused(t *testing.T) {
+	proxyAddr, _ := serveHTTPProxy(t, "407 Proxy Authentication Required", nil)
+
+	_, err := DetectMySQLDialer(context.Background(), &HTTPProxyDialer{ProxyAddr: proxyAddr}, "db.internal:3306")
+	if !errors.Is(err, ErrorHTTPProxy) || !errors.Is(err, ErrorConnect) {
+		t.Errorf("Expected an HTTP proxy connect error, got: %v", err)
+	}
+}
+
+// Proxy that accepts connections and never answers, returning its address
+func serveStalledProxy(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Held open until the dialer gives up and closes its end
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestHTTPProxyDialerCancelled(t *testing.T) {
+	// No deadline, only cancelling can stop waiting for the stalled proxy
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := (&HTTPProxyDialer{ProxyAddr: serveStalledProxy(t)}).DialContext(ctx, "tcp", "db.internal:3306")
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrorHTTPProxy) {
+		t.Errorf("Expected the CONNECT to be cancelled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancelling took %s to abort the CONNECT", elapsed)
+	}
+}
@@ -0,0 +1,161 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+var (
+	// ErrorLoginFailed wraps the *ErrPacket the server rejected the credentials with
+	ErrorLoginFailed = errors.New("MySQL rejected the login")
+
+	// ErrorLoginUnsupported is returned when the server wants an auth method Login can't do,
+	// only mysql_native_password over protocol 4.1 is supported
+	ErrorLoginUnsupported = errors.New("Login is only supported with mysql_native_password")
+)
+
+// Timeout used by Login for connecting and the whole exchange
+const loginTimeout = 10 * time.Second
+
+// Capabilities the client sends in the handshake response, masked by what the server advertised
+const loginCapabilities = ClientLongPassword | ClientProtocol41 | ClientTransactions | ClientSecureConnection | ClientPluginAuth
+
+// Login to host as user with pass, returning nil if the server accepted the credentials
+// See LoginContext
+func Login(host, user, pass string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), loginTimeout)
+	defer cancel()
+
+	return LoginContext(ctx, nil, host, user, pass)
+}
+
+// LoginContext connects to host through dialer and completes the handshake as user with pass using
+// mysql_native_password, a nil dialer connects directly
+// Returns nil when the server accepts the login, ErrorLoginFailed wrapping the server's *ErrPacket when it
+// doesn't, or ErrorLoginUnsupported if the account needs another auth plugin such as caching_sha2_password
+// The connection is closed straight after so no commands are ever run
+// The handshake response is described here:
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeResponse41
+func LoginContext(ctx context.Context, dialer ContextDialer, host, user, pass string) error {
+	conn, err := connect(ctx, dialer, host)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	buf, err := readHandshake(conn)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrorRead, contextErr(ctx, err))
+	}
+	sql, err := decodeHandshake(buf)
+	if err != nil {
+		return fmt.Errorf("Failed to log in to MySQL during decode: %w", err)
+	}
+	if !sql.Protocol41 || sql.Capabilities&ClientSecureConnection == 0 || len(sql.AuthData) < 20 {
+		return fmt.Errorf("%w: server doesn't support protocol 4.1 authentication", ErrorLoginUnsupported)
+	}
+
+	response := handshakeResponse(sql.Capabilities&loginCapabilities, user, NativePasswordScramble(sql.AuthData[:20], pass))
+	if err := writePacket(conn, 1, response); err != nil {
+		return fmt.Errorf("Failed to log in to MySQL during write: %w", contextErr(ctx, err))
+	}
+
+	// A server can ask for the scramble again with a new challenge, but only once
+	for switched := false; ; switched = true {
+		seq, reply, err := readPacketPayload(conn)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrorRead, contextErr(ctx, err))
+		}
+
+		switch reply[0] {
+		case 0x00:
+			return nil
+		case errPacketHeader:
+			return fmt.Errorf("%w: %w", ErrorLoginFailed, decodeErrPacket(append(packetHeader(len(reply), 0), reply...)))
+		case 0xfe:
+			// AuthSwitchRequest, plugin_name(null terminated string) then the new auth data
+			plugin, data, _ := bytes.Cut(reply[1:], []byte{0})
+			if switched || string(plugin) != authPluginNativePassword || len(data) < 20 {
+				return fmt.Errorf("%w: server asked for '%s'", ErrorLoginUnsupported, plugin)
+			}
+			if err := writePacket(conn, seq+1, NativePasswordScramble(data[:20], pass)); err != nil {
+				return fmt.Errorf("Failed to log in to MySQL during write: %w", contextErr(ctx, err))
+			}
+		default:
+			// Most likely caching_sha2_password asking for full authentication which needs TLS or RSA
+			return fmt.Errorf("%w: unexpected reply 0x%02x", ErrorLoginUnsupported, reply[0])
+		}
+	}
+}
+
+// NativePasswordScramble computes the mysql_native_password auth response for the 20 byte scramble
+// SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password))), an empty password sends an empty response
+// https://dev.mysql.com/doc/internals/en/secure-password-authentication.html
+func NativePasswordScramble(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(scramble)
+	h.Write(stage2[:])
+	response := h.Sum(nil)
+	for i := range response {
+		response[i] ^= stage1[i]
+	}
+
+	return response
+}
+
+// HandshakeResponse41 payload, the auth response has a 1 byte length since CLIENT_SECURE_CONNECTION is required
+func handshakeResponse(capabilities uint32, user string, auth []byte) []byte {
+	// Starts the same as an SSL request, then the login itself
+	buf := sslRequest(capabilities)
+	buf = append(buf, user...)
+	buf = append(buf, 0)
+	buf = append(buf, byte(len(auth)))
+	buf = append(buf, auth...)
+	if capabilities&ClientPluginAuth != 0 {
+		buf = append(buf, authPluginNativePassword...)
+		buf = append(buf, 0)
+	}
+
+	return buf
+}
+
+// 4 byte packet header, 3 bytes of payload length then the sequence byte
+func packetHeader(length int, seq byte) []byte {
+	return []byte{byte(length), byte(length >> 8), byte(length >> 16), seq}
+}
+
+func writePacket(w io.Writer, seq byte, payload []byte) error {
+	_, err := w.Write(append(packetHeader(len(payload), seq), payload...))
+	return err
+}
+
+// Read one whole packet from r returning its sequence byte and payload, capped at maxHandshakeSize
+func readPacketPayload(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+
+	pktLen := int(uint32(header[0]) | uint32(header[1])<<8 | uint32(header[2])<<16)
+	if pktLen == 0 || pktLen+4 > maxHandshakeSize {
+		return 0, nil, ErrorMissingData
+	}
+	payload := make([]byte, pktLen)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+
+	return header[3], payload, nil
+}
@@ -0,0 +1,131 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestNativePasswordScramble(t *testing.T) {
+	// The stored hash is SHA1(SHA1(password)), the MySQL docs give PASSWORD('mypass') as this
+	stage1 := sha1.Sum([]byte("mypass"))
+	if stored := sha1.Sum(stage1[:]); hex.EncodeToString(stored[:]) != "6c8989366eaf75bb670ad8ea7a7fc1176a95cef4" {
+		t.Errorf("Stored hash didn't match the documented PASSWORD('mypass'): %x", stored)
+	}
+
+	// auth_plugin_data_part_1 and part_2 from the capture
+	scramble := append(append([]byte{}, normalHandshake[16:24]...), normalHandshake[43:55]...)
+	tests := []struct {
+		password string
+		response string
+	}{
+		{password: "password", response: "389dab4a20a351ed926bdc1e440d174f8fe9b8e4"},
+		{password: "", response: ""},
+	}
+
+	for _, test := range tests {
+		response := NativePasswordScramble(scramble, test.password)
+		if hex.EncodeToString(response) != test.response {
+			t.Errorf("Scramble didn't match expected '%s': %x", test.password, response)
+		}
+		if test.password != "" && !checkNativePassword(scramble, response, test.password) {
+			t.Errorf("Server side check rejected the scramble '%s'", test.password)
+		}
+	}
+}
+
+// Check response the way the server does, SHA1(response XOR SHA1(scramble + stored)) should be the stored hash
+func checkNativePassword(scramble, response []byte, password string) bool {
+	stage1 := sha1.Sum([]byte(password))
+	stored := sha1.Sum(stage1[:])
+	h := sha1.Sum(append(append([]byte{}, scramble...), stored[:]...))
+	if len(response) != len(h) {
+		return false
+	}
+	for i := range h {
+		h[i] ^= response[i]
+	}
+
+	return sha1.Sum(h[:]) == stored
+}
+
+// Fake server that sends the packet capture handshake and accepts user with password
+// switchTo makes it send an AuthSwitchRequest to that plugin first with a new scramble
+func serveLogin(t *testing.T, user, password, switchTo string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	scramble := append(append([]byte{}, normalHandshake[16:24]...), normalHandshake[43:55]...)
+	switchScramble := []byte("abcdefghijklmnopqrst")
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(normalHandshake)
+
+		_, response, err := readPacketPayload(conn)
+		if err != nil || len(response) < 33 {
+			return
+		}
+		// Skip the fixed 32 bytes, then the null terminated user name and length prefixed auth response
+		name, rest, _ := bytes.Cut(response[32:], []byte{0})
+		auth := rest[1 : 1+int(rest[0])]
+
+		if switchTo != "" {
+			writePacket(conn, 2, append(append([]byte{0xfe}, switchTo+"\x00"...), append(switchScramble, 0)...))
+			if _, auth, err = readPacketPayload(conn); err != nil {
+				return
+			}
+			scramble = switchScramble
+		}
+
+		if string(name) == user && checkNativePassword(scramble, auth, password) {
+			writePacket(conn, 4, []byte{0x00, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00})
+			return
+		}
+		writePacket(conn, 4, []byte("\xff\x15\x04#28000Access denied for user '"+string(name)+"'"))
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestLogin(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		switchTo string
+		err      error
+	}{
+		{name: "Correct password", password: "secret"},
+		{name: "Wrong password", password: "guess", err: ErrorLoginFailed},
+		{name: "Auth switch", password: "secret", switchTo: "mysql_native_password"},
+		{name: "Unsupported auth switch", password: "secret", switchTo: "caching_sha2_password", err: ErrorLoginUnsupported},
+	}
+
+	for _, test := range tests {
+		host := serveLogin(t, "root", "secret", test.switchTo)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := LoginContext(ctx, nil, host, "root", test.password)
+		cancel()
+		if !errors.Is(err, test.err) {
+			t.Errorf("Login error didn't match expected '%s': %v", test.name, err)
+		}
+
+		var errPacket *ErrPacket
+		if test.err == ErrorLoginFailed && (!errors.As(err, &errPacket) || errPacket.Code != 1045) {
+			t.Errorf("Expected the server's access denied error '%s': %v", test.name, err)
+		}
+	}
+}
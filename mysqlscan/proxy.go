@@ -0,0 +1,40 @@
+package mysqlscan
+
+import "strings"
+
+// Names returned by LikelyProxy
+const (
+	ProxyProxySQL       = "ProxySQL"
+	ProxyMaxScale       = "MaxScale"
+	ProxyVitess         = "Vitess"
+	ProxyShardingSphere = "ShardingSphere"
+)
+
+// Markers the proxies put in the server version they send, matched case insensitively
+var proxyVersionMarkers = []struct {
+	marker string
+	name   string
+}{
+	// ProxySQL sends its mysql-server_version, 5.5.30 by default, some builds and configs append (ProxySQL)
+	{"proxysql", ProxyProxySQL},
+	// MaxScale appends its own version, such as 5.5.5-10.2.12 2.2.9-maxscale
+	{"maxscale", ProxyMaxScale},
+	// vtgate sends its configured version with -Vitess, such as 8.0.30-Vitess
+	{"vitess", ProxyVitess},
+	// ShardingSphere-Proxy appends its name, such as 5.7.22-ShardingSphere-Proxy 5.4.1
+	{"shardingsphere", ProxyShardingSphere},
+}
+
+// LikelyProxy reports whether the handshake came from a proxy or router in front of MySQL, such as ProxySQL or
+// MaxScale, rather than a database server, along with the proxy's name. It only looks for the markers the
+// proxies are known to put in the server version, a proxy configured to send a plain version isn't spotted
+func (s *MySQLv10) LikelyProxy() (bool, string) {
+	lower := strings.ToLower(s.ServerVersion)
+	for _, m := range proxyVersionMarkers {
+		if strings.Contains(lower, m.marker) {
+			return true, m.name
+		}
+	}
+
+	return false, ""
+}
@@ -0,0 +1,48 @@
+package mysqlscan
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLikelyProxy(t *testing.T) {
+	tests := []struct {
+		version string
+		proxy   string
+	}{
+		{"5.5.30 (ProxySQL)", ProxyProxySQL},
+		{"5.5.5-10.2.12 2.2.9-maxscale", ProxyMaxScale},
+		{"8.0.30-Vitess", ProxyVitess},
+		{"5.7.22-ShardingSphere-Proxy 5.4.1", ProxyShardingSphere},
+		{"8.0.21", ""},
+		{"10.6.12-MariaDB", ""},
+		{"5.7.40-43-log", ""},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{ServerVersion: test.version}
+		likely, proxy := sql.LikelyProxy()
+		if likely != (test.proxy != "") || proxy != test.proxy {
+			t.Errorf("%s: expected proxy '%s', got %t '%s'", test.version, test.proxy, likely, proxy)
+		}
+	}
+
+	// Reported in the output of a decoded ProxySQL handshake
+	buf := append(append([]byte{0, 0, 0, 0, 10}, "5.5.30 (ProxySQL)"...), normalHandshake[11:]...)
+	buf[0] = byte(len(buf) - 4)
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !strings.Contains(sql.String(), "Proxy:ProxySQL") {
+		t.Errorf("String didn't report the proxy: %s", sql.String())
+	}
+	out, err := json.Marshal(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"proxy":"ProxySQL"`) {
+		t.Errorf("JSON didn't report the proxy: %s", out)
+	}
+}
@@ -0,0 +1,64 @@
+package mysqlscan
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// Token bucket limiting how often hosts are dialed for ScanOptions.Rate, shared by every worker in the pool
+// The bucket holds a single token so connections are spread evenly instead of bursting, with up to
+// jitter of random delay added to each so the timing doesn't look like a scanner to an IDS
+type rateLimiter struct {
+	interval time.Duration
+	jitter   time.Duration
+
+	mu sync.Mutex
+	// next is when the next token is available
+	next time.Time
+}
+
+// Create a limiter allowing rate connections per second, a rate of 0 or less only applies the jitter
+func newRateLimiter(rate float64, jitter time.Duration) *rateLimiter {
+	l := &rateLimiter{jitter: jitter}
+	// A rate so high the interval rounds down to nothing is as good as unlimited
+	if rate > 0 {
+		l.interval = time.Duration(float64(time.Second) / rate)
+	}
+
+	return l
+}
+
+// Wait blocks until a connection is allowed or ctx is done, a nil limiter never waits
+// Tokens are reserved in the order Wait is called so workers are let through one interval apart
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if l.jitter > 0 {
+		delay += rand.N(l.jitter)
+	}
+	if delay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,41 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	// The first connection goes straight away, each one after waits a full interval
+	limiter := newRateLimiter(100, 0)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatalf("Failed to wait for the limiter: %s", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("5 connections at 100 per second took %s, expected at least 40ms", elapsed)
+	}
+
+	// Nobody is waiting on a nil limiter
+	var none *rateLimiter
+	if err := none.Wait(context.Background()); err != nil {
+		t.Errorf("Nil limiter returned an error: %s", err)
+	}
+}
+
+func TestRateLimiterCancel(t *testing.T) {
+	limiter := newRateLimiter(0.1, 0)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatalf("Failed to wait for the limiter: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := limiter.Wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait didn't match expected '%s': %v", context.DeadlineExceeded, err)
+	}
+}
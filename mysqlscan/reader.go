@@ -0,0 +1,23 @@
+package mysqlscan
+
+import (
+	"errors"
+	"io"
+)
+
+// DecodeReader reads one handshake packet from r and decodes it, for offline analysis of captured handshakes
+// such as a file, bytes.Buffer or TCP stream extracted from a pcap
+// Only the packet is read so consecutive packets can be decoded by calling it again, io.EOF is returned
+// once r is empty. Packets are framed, capped and bounds checked by the same code as DetectMySQL,
+// so a partial or oversized packet is ErrorMissingData or ErrorNotMySQL
+func DecodeReader(r io.Reader) (*MySQLv10, error) {
+	buf, err := readHandshake(r)
+	if errors.Is(err, ErrorConnectionClosed) {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeHandshake(buf)
+}
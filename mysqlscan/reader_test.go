@@ -0,0 +1,44 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestDecodeReader(t *testing.T) {
+	// Back to back packets like a capture file, each call should only consume its own packet
+	r := bytes.NewReader(append(append([]byte{}, normalHandshake...), v9Handshake...))
+
+	sql, err := DecodeReader(r)
+	if err != nil {
+		t.Fatalf("Failed to decode first handshake: %s", err)
+	}
+	if sql.ProtocolVersion != 10 || sql.ServerVersion != "8.0.21" || !bytes.Equal(sql.RawPacket, normalHandshake) {
+		t.Errorf("First handshake didn't match expected: %+v", sql)
+	}
+
+	sql, err = DecodeReader(r)
+	if err != nil {
+		t.Fatalf("Failed to decode second handshake: %s", err)
+	}
+	if sql.ProtocolVersion != 9 || sql.ServerVersion != "3.20.32" {
+		t.Errorf("Second handshake didn't match expected: %+v", sql)
+	}
+
+	if _, err := DecodeReader(r); err != io.EOF {
+		t.Errorf("Expected io.EOF once the reader is empty, got: %v", err)
+	}
+
+	// Truncated packets are missing data whether they end in the header or the payload
+	for _, n := range []int{2, 40} {
+		if _, err := DecodeReader(bytes.NewReader(normalHandshake[:n])); !errors.Is(err, ErrorMissingData) {
+			t.Errorf("Expected ErrorMissingData truncated at %d, got: %v", n, err)
+		}
+	}
+
+	if _, err := DecodeReader(bytes.NewReader([]byte("SSH-2.0-OpenSSH_8.9\r\n"))); !errors.Is(err, ErrorNotMySQL) {
+		t.Errorf("Expected ErrorNotMySQL for an SSH banner, got: %v", err)
+	}
+}
@@ -0,0 +1,116 @@
+package mysqlscan
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// Resolver looks up the addresses of a hostname, *net.Resolver implements it
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// CachingDialer resolves each hostname once and dials the cached addresses for every later connection,
+// so scanning many ports or retrying a host doesn't repeat the DNS lookup
+// Lookups in flight are shared, a failed lookup isn't cached so the next connection tries again
+// Resolving happens before Forward is dialed, don't use it in front of a proxy that should do the lookup
+type CachingDialer struct {
+	// Forward dials the resolved address, a nil Forward connects directly
+	Forward ContextDialer
+
+	// Resolver looks up hostnames, a nil Resolver uses net.DefaultResolver
+	Resolver Resolver
+
+	mu    sync.Mutex
+	cache map[string]*resolved
+}
+
+// Addresses of a hostname, done is closed once the lookup finishes
+type resolved struct {
+	done  chan struct{}
+	addrs []string
+	err   error
+}
+
+// NewCachingDialer that resolves hostnames once and dials through forward, nil connects directly
+func NewCachingDialer(forward ContextDialer) *CachingDialer {
+	return &CachingDialer{Forward: forward}
+}
+
+// DialContext resolves the host part of address, using the cache if it has been resolved before,
+// and dials each of its addresses in turn until one connects
+func (d *CachingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	if net.ParseIP(host) != nil {
+		return forward.DialContext(ctx, network, address)
+	}
+
+	addrs, err := d.lookup(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like net.Dialer the first error is returned, it's the address most likely to be the right one
+	var firstErr error
+	for _, addr := range addrs {
+		conn, err := forward.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, firstErr
+}
+
+// Addresses of host from the cache, looking them up if this is the first time host is dialed
+func (d *CachingDialer) lookup(ctx context.Context, host string) ([]string, error) {
+	d.mu.Lock()
+	if d.cache == nil {
+		d.cache = map[string]*resolved{}
+	}
+	entry, ok := d.cache[host]
+	if !ok {
+		entry = &resolved{done: make(chan struct{})}
+		d.cache[host] = entry
+	}
+	d.mu.Unlock()
+
+	if !ok {
+		resolver := d.Resolver
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		entry.addrs, entry.err = resolver.LookupHost(ctx, host)
+		if entry.err == nil && len(entry.addrs) == 0 {
+			entry.err = &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		if entry.err != nil {
+			d.mu.Lock()
+			delete(d.cache, host)
+			d.mu.Unlock()
+		}
+		close(entry.done)
+	}
+
+	select {
+	case <-entry.done:
+		return entry.addrs, entry.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,92 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// Resolver that counts lookups, answering every host with 127.0.0.1
+type countingResolver struct {
+	lookups atomic.Int32
+	err     error
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.lookups.Add(1)
+	if r.err != nil {
+		return nil, r.err
+	}
+	return []string{"127.0.0.1"}, nil
+}
+
+func TestCachingDialer(t *testing.T) {
+	// A listener per port, all of them on the same host
+	var ports []string
+	for i := 0; i < 3; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %s", err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				conn.Write(normalHandshake)
+				conn.Close()
+			}
+		}()
+		ports = append(ports, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port))
+	}
+
+	resolver := &countingResolver{}
+	dialer := &CachingDialer{Resolver: resolver}
+
+	// Concurrent dials share the lookup in flight and retries reuse the cached one
+	var wg sync.WaitGroup
+	for _, port := range append(ports, ports...) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := DetectMySQLDialer(context.Background(), dialer, net.JoinHostPort("db.example", port)); err != nil {
+				t.Errorf("Failed to detect MySQL on port %s: %s", port, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if lookups := resolver.lookups.Load(); lookups != 1 {
+		t.Errorf("Expected a single lookup for every port, got %d", lookups)
+	}
+
+	// IP addresses don't need resolving
+	if _, err := DetectMySQLDialer(context.Background(), dialer, net.JoinHostPort("127.0.0.1", ports[0])); err != nil {
+		t.Errorf("Failed to detect MySQL: %s", err)
+	}
+	if lookups := resolver.lookups.Load(); lookups != 1 {
+		t.Errorf("Expected no lookup for an IP address, got %d", lookups)
+	}
+}
+
+func TestCachingDialerLookupFailed(t *testing.T) {
+	errLookup := errors.New("lookup failed")
+	resolver := &countingResolver{err: errLookup}
+	dialer := &CachingDialer{Resolver: resolver}
+
+	// Failures aren't cached so each attempt looks the host up again
+	for i := 0; i < 2; i++ {
+		if _, err := dialer.DialContext(context.Background(), "tcp", "db.example:3306"); !errors.Is(err, errLookup) {
+			t.Errorf("Dial didn't match expected '%s': %v", errLookup, err)
+		}
+	}
+	if lookups := resolver.lookups.Load(); lookups != 2 {
+		t.Errorf("Expected a lookup for each attempt, got %d", lookups)
+	}
+}
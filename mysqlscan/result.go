@@ -0,0 +1,53 @@
+package mysqlscan
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ScanResult is the outcome of scanning a single host as part of a bulk scan
+type ScanResult struct {
+	Host string
+
+	// MySQL is the decoded handshake, nil unless MySQL was detected
+	MySQL *MySQLv10
+
+	// Err is set when MySQL wasn't detected on the host
+	Err error
+
+	// Duration is how long the host took to scan, including any retries
+	Duration time.Duration
+}
+
+// MarshalJSON encodes the result as a single record with the error message in place of Err,
+// and the DialError category if the host couldn't be connected to
+func (r *ScanResult) MarshalJSON() ([]byte, error) {
+	record := struct {
+		Host      string    `json:"host"`
+		Success   bool      `json:"success"`
+		Error     string    `json:"error,omitempty"`
+		DialError string    `json:"dial_error,omitempty"`
+		Duration  string    `json:"duration"`
+		MySQL     *MySQLv10 `json:"mysql,omitempty"`
+	}{
+		Host:     r.Host,
+		Success:  r.Err == nil,
+		Duration: r.Duration.String(),
+		MySQL:    r.MySQL,
+	}
+	if r.Err != nil {
+		record.Error = r.Err.Error()
+	}
+	var dialErr *DialError
+	if errors.As(r.Err, &dialErr) {
+		record.DialError = dialErr.Category
+	}
+
+	return json.Marshal(&record)
+}
+
+// Reachable is true if the host accepted a connection, whether or not it's running MySQL
+func (r *ScanResult) Reachable() bool {
+	return r.Err == nil || !errors.Is(r.Err, ErrorConnect)
+}
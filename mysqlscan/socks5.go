@@ -0,0 +1,202 @@
+package mysqlscan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// SOCKS5 protocol values, described here:
+// https://datatracker.ietf.org/doc/html/rfc1928
+// https://datatracker.ietf.org/doc/html/rfc1929
+const (
+	socks5Version        = 0x05
+	socks5AuthNone       = 0x00
+	socks5AuthPassword   = 0x02
+	socks5AuthNoMethods  = 0xff
+	socks5PasswordStatus = 0x01
+	socks5CmdConnect     = 0x01
+	socks5AddrIPv4       = 0x01
+	socks5AddrDomain     = 0x03
+	socks5AddrIPv6       = 0x04
+	socks5ReplySucceeded = 0x00
+)
+
+var ErrorSOCKS5 = errors.New("SOCKS5 proxy handshake failed")
+
+// SOCKS5Dialer connects to hosts through a SOCKS5 proxy, such as one set up by ssh -D
+// This is a small CONNECT only client so the tool keeps to the standard library
+type SOCKS5Dialer struct {
+	// ProxyAddr is the host:port of the SOCKS5 proxy
+	ProxyAddr string
+
+	// Username and Password for proxies that require RFC 1929 authentication, leave empty for none
+	Username string
+	Password string
+
+	// Forward dials the proxy itself, a nil Forward connects directly
+	Forward ContextDialer
+}
+
+// NewSOCKS5Dialer from a URL in the form socks5://[user:pass@]host:port
+func NewSOCKS5Dialer(rawURL string) (*SOCKS5Dialer, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" || u.Host == "" {
+		return nil, fmt.Errorf("Proxy URL must be in the form socks5://host:port, got '%s'", rawURL)
+	}
+
+	d := &SOCKS5Dialer{ProxyAddr: u.Host}
+	if u.User != nil {
+		d.Username = u.User.Username()
+		d.Password, _ = u.User.Password()
+	}
+
+	return d, nil
+}
+
+// DialContext connects to the proxy and asks it to CONNECT to address
+func (d *SOCKS5Dialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	conn, err := forward.DialContext(ctx, network, d.ProxyAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The proxy handshake is part of dialing so it shares the dial deadline, and cancelling ctx aborts it
+	// by moving the deadline up to now so a stalled proxy can't hold up an interrupted scan
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	err = d.handshake(conn, address)
+	if !stop() {
+		conn.Close()
+		return nil, fmt.Errorf("%w: %w", ErrorSOCKS5, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return conn, nil
+}
+
+// Negotiate authentication then send the CONNECT request for address
+func (d *SOCKS5Dialer) handshake(conn net.Conn, address string) error {
+	method := byte(socks5AuthNone)
+	if d.Username != "" {
+		method = socks5AuthPassword
+	}
+
+	// version(1) nmethods(1) methods(nmethods)
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+
+	// version(1) method(1)
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != socks5Version || reply[1] == socks5AuthNoMethods || reply[1] != method {
+		return fmt.Errorf("%w: no acceptable authentication method", ErrorSOCKS5)
+	}
+
+	if method == socks5AuthPassword {
+		if len(d.Username) > 255 || len(d.Password) > 255 {
+			return fmt.Errorf("%w: username and password must be at most 255 bytes", ErrorSOCKS5)
+		}
+
+		// version(1) ulen(1) uname(ulen) plen(1) passwd(plen)
+		req := []byte{socks5PasswordStatus, byte(len(d.Username))}
+		req = append(req, d.Username...)
+		req = append(req, byte(len(d.Password)))
+		req = append(req, d.Password...)
+		if _, err := conn.Write(req); err != nil {
+			return err
+		}
+
+		// version(1) status(1)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("%w: authentication rejected", ErrorSOCKS5)
+		}
+	}
+
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return err
+	}
+
+	// version(1) cmd(1) reserved(1) atyp(1) dst.addr(variable) dst.port(2)
+	req := []byte{socks5Version, socks5CmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		if len(host) > 255 {
+			return fmt.Errorf("%w: host name too long", ErrorSOCKS5)
+		}
+		req = append(req, socks5AddrDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, socks5AddrIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, socks5AddrIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = binary.BigEndian.AppendUint16(req, uint16(port))
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	// version(1) rep(1) reserved(1) atyp(1) bnd.addr(variable) bnd.port(2)
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != socks5Version {
+		return fmt.Errorf("%w: unexpected version %d", ErrorSOCKS5, header[0])
+	}
+	if header[1] != socks5ReplySucceeded {
+		return fmt.Errorf("%w: CONNECT to %s failed with reply code %d", ErrorSOCKS5, address, header[1])
+	}
+
+	// The bound address isn't useful, but it has to be read off before the MySQL handshake
+	var addrLen int
+	switch header[3] {
+	case socks5AddrIPv4:
+		addrLen = net.IPv4len
+	case socks5AddrIPv6:
+		addrLen = net.IPv6len
+	case socks5AddrDomain:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return err
+		}
+		addrLen = int(l[0])
+	default:
+		return fmt.Errorf("%w: unknown address type %d", ErrorSOCKS5, header[3])
+	}
+
+	_, err = io.ReadFull(conn, make([]byte, addrLen+2))
+	return err
+}
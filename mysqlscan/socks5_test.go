@@ -0,0 +1,152 @@
+package mysqlscan
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Dialer that hands out one end of a pipe with a canned handshake written to it
+type fakeDialer struct {
+	packet []byte
+	dialed []string
+}
+
+func (d *fakeDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed = append(d.dialed, address)
+
+	client, server := net.Pipe()
+	go func() {
+		server.Write(d.packet)
+		server.Close()
+	}()
+
+	return client, nil
+}
+
+func TestDetectMySQLDialer(t *testing.T) {
+	dialer := &fakeDialer{packet: normalHandshake}
+	sql, err := DetectMySQLDialer(context.Background(), dialer, "db.internal:3306")
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL through the dialer: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Server version didn't match expected: %s", sql.ServerVersion)
+	}
+	if len(dialer.dialed) != 1 || dialer.dialed[0] != "db.internal:3306" {
+		t.Errorf("Expected the dialer to be used for db.internal:3306, dialed: %v", dialer.dialed)
+	}
+}
+
+// Minimal SOCKS5 server that accepts one connection with the given credentials,
+// records the requested CONNECT address and then answers with packet like MySQL would
+func serveSOCKS5(t *testing.T, username, password string, packet []byte) (string, <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	requested := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		greeting := make([]byte, 3)
+		io.ReadFull(conn, greeting)
+		conn.Write([]byte{socks5Version, greeting[2]})
+
+		if greeting[2] == socks5AuthPassword {
+			head := make([]byte, 2)
+			io.ReadFull(conn, head)
+			user := make([]byte, head[1])
+			io.ReadFull(conn, user)
+			plen := make([]byte, 1)
+			io.ReadFull(conn, plen)
+			pass := make([]byte, plen[0])
+			io.ReadFull(conn, pass)
+
+			if string(user) != username || string(pass) != password {
+				conn.Write([]byte{socks5PasswordStatus, 1})
+				return
+			}
+			conn.Write([]byte{socks5PasswordStatus, 0})
+		}
+
+		req := make([]byte, 5)
+		io.ReadFull(conn, req)
+		addr := make([]byte, req[4])
+		io.ReadFull(conn, addr)
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+		requested <- net.JoinHostPort(string(addr), strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+		conn.Write([]byte{socks5Version, socks5ReplySucceeded, 0, socks5AddrIPv4, 127, 0, 0, 1, 0x0c, 0xea})
+		conn.Write(packet)
+	}()
+
+	return ln.Addr().String(), requested
+}
+
+func TestSOCKS5Dialer(t *testing.T) {
+	proxyAddr, requested := serveSOCKS5(t, "scanner", "secret", normalHandshake)
+
+	dialer, err := NewSOCKS5Dialer("socks5://scanner:secret@" + proxyAddr)
+	if err != nil {
+		t.Fatalf("Failed to parse proxy URL: %s", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	sql, err := DetectMySQLDialer(ctx, dialer, "db.internal:3306")
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL through the SOCKS5 proxy: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Server version didn't match expected: %s", sql.ServerVersion)
+	}
+	if addr := <-requested; addr != "db.internal:3306" {
+		t.Errorf("Proxy was asked to CONNECT to '%s'", addr)
+	}
+}
+
+func TestSOCKS5DialerBadPassword(t *testing.T) {
+	proxyAddr, _ := serveSOCKS5(t, "scanner", "secret", normalHandshake)
+
+	dialer := &SOCKS5Dialer{ProxyAddr: proxyAddr, Username: "scanner", Password: "wrong"}
+	_, err := DetectMySQLDialer(context.Background(), dialer, "db.internal:3306")
+	if !errors.Is(err, ErrorSOCKS5) || !errors.Is(err, ErrorConnect) {
+		t.Errorf("Expected a SOCKS5 connect error, got: %v", err)
+	}
+}
+
+func TestSOCKS5DialerCancelled(t *testing.T) {
+	// No deadline, only cancelling can stop waiting for the stalled proxy
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := (&SOCKS5Dialer{ProxyAddr: serveStalledProxy(t)}).DialContext(ctx, "tcp", "db.internal:3306")
+	if !errors.Is(err, context.Canceled) || !errors.Is(err, ErrorSOCKS5) {
+		t.Errorf("Expected the proxy handshake to be cancelled, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Cancelling took %s to abort the proxy handshake", elapsed)
+	}
+}
+
+func TestNewSOCKS5Dialer(t *testing.T) {
+	for _, rawURL := range []string{"http://127.0.0.1:1080", "socks5://", "127.0.0.1:1080"} {
+		if _, err := NewSOCKS5Dialer(rawURL); err == nil {
+			t.Errorf("Expected an error for proxy URL '%s'", rawURL)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package mysqlscan
+
+import (
+	"fmt"
+	"net"
+)
+
+// NewSourceDialer that connects from the local IP address ip, for choosing which interface a scan
+// leaves through on a host with more than one network. The port is picked by the system
+func NewSourceDialer(ip string) (*net.Dialer, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("Source address must be an IP address, got '%s'", ip)
+	}
+
+	return &net.Dialer{LocalAddr: &net.TCPAddr{IP: parsed}}, nil
+}
@@ -0,0 +1,39 @@
+package mysqlscan
+
+import (
+	"context"
+	"net"
+	"testing"
+)
+
+func TestNewSourceDialer(t *testing.T) {
+	dialer, err := NewSourceDialer("127.0.0.1")
+	if err != nil {
+		t.Fatalf("Failed to create dialer: %s", err)
+	}
+	local, ok := dialer.LocalAddr.(*net.TCPAddr)
+	if !ok || !local.IP.Equal(net.IPv4(127, 0, 0, 1)) || local.Port != 0 {
+		t.Fatalf("LocalAddr didn't match expected: %v", dialer.LocalAddr)
+	}
+
+	// Connections come from the source address
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	conn, err := dialer.DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer conn.Close()
+	if addr := conn.LocalAddr().(*net.TCPAddr); !addr.IP.Equal(local.IP) {
+		t.Errorf("Connection didn't come from the source address: %s", addr)
+	}
+
+	for _, ip := range []string{"", "eth0", "10.0.0.1:3306"} {
+		if _, err := NewSourceDialer(ip); err == nil {
+			t.Errorf("Expected an error for source '%s'", ip)
+		}
+	}
+}
@@ -0,0 +1,923 @@
+// Package mysqlscan detects MySQL servers by connecting and decoding the handshake packet they send
+// The mysql-scan command is a thin wrapper around this package
+package mysqlscan
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// MySQLv10 is the MySQL v10 handshake packet
+// This packet is described here:
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::Handshake
+type MySQLv10 struct {
+	// ProtocolVersion of the handshake, this is always 10 unless converted from a MySQLv9 handshake
+	// or decoded with DecodeOptions.AcceptAnyProtocolVersion, in which case it's whatever the server sent
+	ProtocolVersion uint8 `json:"protocol_version"`
+
+	// ServerVersion in human readable version
+	ServerVersion string `json:"server_version"`
+
+	// ConnectionId from the handshake packet, the server's thread ID for this connection
+	// It counts up from 1 as connections are made, see LowConnectionId
+	ConnectionId uint32 `json:"connection_id"`
+
+	// CharacterSet default character set, this is  collation ID in the table from the link
+	// https://dev.mysql.com/doc/internals/en/character-set.html#packet-Protocol::CharacterSet
+	CharacterSet uint8 `json:"character_set"`
+
+	// Status is a bit-field of status flags described here:
+	// https://dev.mysql.com/doc/internals/en/status-flags.html#packet-Protocol::StatusFlags
+	// Referred to as status_flags in the handshake doc
+	Status uint16 `json:"status"`
+
+	// Capabilities are the capability flags described here:
+	// https://dev.mysql.com/doc/internals/en/capability-flags.html#packet-Protocol::CapabilityFlags
+	// Combined capability_flags_1 and capability_flags_2 (if capability_flags_2 existed) from handshake doc
+	Capabilities uint32 `json:"capabilities"`
+
+	// AuthPlugin is the name of the authentication method
+	// Referred to as auth_plugin_name in the handshake doc
+	AuthPlugin string `json:"auth_plugin"`
+
+	// CachingSHA2 is set when AuthPlugin is caching_sha2_password, the MySQL 8 default, for tracking which
+	// servers have moved off mysql_native_password. Its auth data is a 20 byte nonce for the SHA256 scramble
+	// that the server caches a successful login against so later logins take the fast path
+	CachingSHA2 bool `json:"caching_sha2"`
+
+	// AuthData is the combined auth plugin data
+	// Referred to as auth_plugin_data_part_1 and auth_plugin_data_part_2 from handshake doc
+	// This is commonly called the Cipher or Salt, but depends on the auth plugin
+	AuthData []byte `json:"auth_data"`
+
+	// AuthDataPart1 and AuthDataPart2 are AuthData split back into auth_plugin_data_part_1, always the first
+	// 8 bytes, and auth_plugin_data_part_2, the rest, which is nil without ClientSecureConnection
+	// Both share AuthData's memory, they're nil for a converted v9 handshake which only has the one scramble
+	AuthDataPart1 []byte `json:"auth_data_part1"`
+	AuthDataPart2 []byte `json:"auth_data_part2"`
+
+	// AuthDataLen is the auth_plugin_data_len byte, the length of the auth data the server declared
+	// including its null terminator. It's only meaningful with ClientPluginAuth, otherwise it's zero
+	// Compare it against len(AuthData)+1 to spot servers whose declared length doesn't match what they sent
+	AuthDataLen uint8 `json:"auth_data_len"`
+
+	// Protocol41 is set when the server advertises ClientProtocol41
+	// Servers without it are ancient and use a layout Decode only partially understands,
+	// so the auth data in particular may not be reliable
+	Protocol41 bool `json:"protocol_41"`
+
+	// Latency is how long DetectMySQL took from dialing until the handshake was read
+	// This isn't part of the handshake so it's zero when only decoding
+	Latency time.Duration `json:"-"`
+
+	// RawPacket is a copy of the exact packet decoded, header included, kept as evidence to re-analyze later
+	// Encoded in JSON as hex under raw
+	RawPacket []byte `json:"-"`
+
+	// Extra is whatever followed the handshake packet in the buffer decoded, nil when the packet filled it
+	// DetectMySQL keeps anything the server sent along with the handshake, a sign of a proxy or wrapper in
+	// front of MySQL. Encoded in JSON as hex under extra
+	Extra []byte `json:"-"`
+
+	// Suspicious is set when the handshake's auth_plugin_data_len is implausibly short or claims more auth
+	// data than the packet holds, which real servers never send but honeypots and broken servers might
+	Suspicious bool `json:"suspicious"`
+
+	// NonZeroReserved is set when the reserved bytes after auth_plugin_data_len aren't all zero like the spec says
+	// Real servers zero them but fake ones often don't, which makes it a useful fingerprint
+	NonZeroReserved bool `json:"non_zero_reserved"`
+
+	// WeakScramble is set when AuthData has so little variation, such as every byte the same or part 1 repeated
+	// as part 2, that it can't have come from a real server's random scramble, likely a honeypot replaying a fake
+	WeakScramble bool `json:"weak_scramble"`
+
+	// BannerOnly is set when only the protocol version and server version were decoded by DecodeBanner,
+	// every other field is left zero rather than being read from the packet
+	BannerOnly bool `json:"banner_only,omitempty"`
+
+	// TLS is the connection the server negotiated when the handshake was detected with DetectMySQLTLS,
+	// nil otherwise
+	TLS *TLSInfo `json:"tls,omitempty"`
+
+	// ForcedDecode is set when the protocol_version wasn't 10 but the packet was decoded as a v10 handshake
+	// anyway because of DecodeOptions.AcceptAnyProtocolVersion, the fields are a best effort guess
+	ForcedDecode bool `json:"forced_decode,omitempty"`
+}
+
+// Largest handshake packet DetectMySQL will read, including the 4 byte header
+// Real handshakes are well under 1KB, the buffer is sized from the packet length so this only bounds
+// what a hostile server can make us allocate
+const maxHandshakeSize = 1 << 16
+
+var (
+	ErrorMissingData     = errors.New("Not enough data received for MySQLv10 handshake")
+	ErrorInvalidProtocol = errors.New("MySQL Handshake version doesn't match expected")
+
+	// ErrorNotMySQL is returned when the peer answered with something that isn't a MySQL handshake at all
+	// such as an SSH banner or the X Protocol on port 33060 (the more specific ErrorXProtocol), as opposed to
+	// a MySQL handshake that is truncated or an unsupported version
+	ErrorNotMySQL = errors.New("Data received isn't a MySQL classic protocol handshake")
+
+	ErrorUnexpectedSequence = errors.New("MySQL handshake packet sequence isn't zero")
+
+	// ErrorConnect wraps every error from dialing, so unreachable hosts can be told apart from other failures
+	// The dial error itself is a *DialError saying whether it was refused, timed out or had no route
+	ErrorConnect = errors.New("Failed to detect MySQL during connect")
+
+	// ErrorRead wraps every error from reading the handshake after connecting
+	ErrorRead = errors.New("Failed to detect MySQL during read")
+
+	// ErrorConnectionClosed is wrapped by ErrorRead when the server accepted the connection then closed it
+	// without sending anything, typical of connection limits and proxies with no backend
+	ErrorConnectionClosed = errors.New("Connection closed by the server before sending a handshake")
+
+	// ErrorConnectionReset is wrapped by ErrorRead when the connection was reset before the whole handshake
+	// arrived, some L4 load balancers reset the first connection of a flow then work on the next one
+	ErrorConnectionReset = errors.New("Connection reset during the handshake")
+
+	ErrorBareIPv6 = errors.New("IPv6 addresses must be in brackets followed by the port, e.g. [::1]:3306")
+)
+
+// DetectMySQL on the given host
+// Use timeout parameter in seconds as the deadline for both dialing the connection and reading the handshake
+func DetectMySQL(host string, timeout int) (*MySQLv10, error) {
+	return DetectMySQLTimeout(host, time.Second*time.Duration(timeout))
+}
+
+// DetectMySQLTimeout on the given host
+// Same as DetectMySQL but with a finer grained timeout than whole seconds
+func DetectMySQLTimeout(host string, timeout time.Duration) (*MySQLv10, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return DetectMySQLContext(ctx, host)
+}
+
+// DetectMySQLContext on the given host
+// The context can cancel the scan at any point, its deadline applies to both the dial and the read
+func DetectMySQLContext(ctx context.Context, host string) (*MySQLv10, error) {
+	return DetectMySQLDialer(ctx, nil, host)
+}
+
+// ContextDialer dials the connection to the host, such as a net.Dialer or a SOCKS5Dialer
+type ContextDialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// DetectMySQLDialer on the given host, connecting through dialer
+// A nil dialer connects directly the same as DetectMySQLContext
+// Each step is logged to slog.Default at debug level, with the host and bytes read
+func DetectMySQLDialer(ctx context.Context, dialer ContextDialer, host string) (*MySQLv10, error) {
+	return detect(ctx, dialer, host, func(r io.Reader) ([]byte, error) {
+		return readWithExtra(r, maxHandshakeSize, readHandshake)
+	}, decodeHandshake)
+}
+
+// DetectMySQLOptions is DetectMySQLDialer decoding the handshake with opts
+func DetectMySQLOptions(ctx context.Context, dialer ContextDialer, host string, opts DecodeOptions) (*MySQLv10, error) {
+	return detect(ctx, dialer, host, func(r io.Reader) ([]byte, error) {
+		return readWithExtra(r, cmp.Or(opts.MaxBytes, maxHandshakeSize), func(r io.Reader) ([]byte, error) {
+			return readHandshakeOptions(r, opts)
+		})
+	}, func(buf []byte) (*MySQLv10, error) {
+		return decodeHandshakeOptions(buf, opts)
+	})
+}
+
+// Connect to host, read a packet with read and decode it with decode
+// Shared by the full handshake and banner only detection which only differ in how much they read and decode
+func detect(ctx context.Context, dialer ContextDialer, host string, read func(io.Reader) ([]byte, error), decode func([]byte) (*MySQLv10, error)) (*MySQLv10, error) {
+	start := time.Now()
+	conn, err := connect(ctx, dialer, host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return detectConn(ctx, conn, host, start, read, decode)
+}
+
+// DetectMySQLConn decodes the handshake from a connection that is already established, such as one
+// through a multiplexed tunnel, without dialing
+// timeout is the read deadline set on conn, zero leaves any existing deadline alone
+// conn is left open for the caller to close
+func DetectMySQLConn(conn net.Conn, timeout time.Duration) (*MySQLv10, error) {
+	if timeout > 0 {
+		conn.SetReadDeadline(time.Now().Add(timeout))
+	}
+
+	return detectConn(context.Background(), conn, conn.RemoteAddr().String(), time.Now(), func(r io.Reader) ([]byte, error) {
+		return readWithExtra(r, maxHandshakeSize, readHandshake)
+	}, decodeHandshake)
+}
+
+// Read and decode the handshake from conn, errors are wrapped the same whether or not we dialed it
+// Latency is measured from start, which is before the dial when there was one
+func detectConn(ctx context.Context, conn net.Conn, host string, start time.Time, read func(io.Reader) ([]byte, error), decode func([]byte) (*MySQLv10, error)) (*MySQLv10, error) {
+	buf, err := read(conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorRead, contextErr(ctx, err))
+	}
+	latency := time.Since(start)
+	slog.Debug("Read handshake", "host", host, "bytes", len(buf), "latency", latency)
+
+	sql, err := decode(buf)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to detect MySQL during decode: %w", err)
+	}
+	sql.Latency = latency
+	slog.Debug("Decoded handshake", "host", host, "protocol_version", sql.ProtocolVersion, "server_version", sql.ServerVersion)
+
+	return sql, nil
+}
+
+// Dial host through dialer with the context deadline applied to the connection, errors wrap ErrorConnect
+// Cancelling the context expires the connection's deadline, closing the connection releases that
+func connect(ctx context.Context, dialer ContextDialer, host string) (net.Conn, error) {
+	if err := validateHost(host); err != nil {
+		return nil, fmt.Errorf("Failed to detect MySQL, invalid host '%s': %w", host, err)
+	}
+
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	slog.Debug("Dialing", "host", host)
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorConnect, newDialError(contextErr(ctx, err)))
+	}
+
+	// Reads don't take a context, so push the deadline onto the connection instead
+	// and expire it immediately if the context is cancelled mid-read
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() {
+		conn.SetDeadline(time.Unix(1, 0))
+	})
+
+	return &contextConn{Conn: conn, stop: stop}, nil
+}
+
+// Connection that stops watching its context once closed
+type contextConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *contextConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
+// Check host is in host:port form, IPv6 addresses have to be bracketed like [::1]:3306
+func validateHost(host string) error {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		// Without brackets there is no telling where the address ends and the port starts
+		if strings.Count(host, ":") > 1 {
+			return ErrorBareIPv6
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Read a handshake packet from r, which may arrive over several reads on a fragmented connection
+// The header is read first and the buffer sized to the packet length it gives, so exactly one packet is read
+// Anything that doesn't start like a MySQL packet is returned after the protocol_version byte rather than
+// waiting for however long its first bytes happen to decode as, and the packet is capped at
+// maxHandshakeSize so a malicious server can't force huge allocations
+func readHandshake(r io.Reader) ([]byte, error) {
+	return readHandshakeOptions(r, DecodeOptions{})
+}
+
+// Read a packet from r with read then keep anything else the server had already sent in the same reads,
+// such as a second packet from a chatty server or a proxy. Nothing more is waited for, no more than limit
+// bytes are read from r in all
+func readWithExtra(r io.Reader, limit int, read func(io.Reader) ([]byte, error)) ([]byte, error) {
+	br := bufio.NewReader(io.LimitReader(r, int64(limit)))
+	buf, err := read(br)
+	if err != nil || br.Buffered() == 0 {
+		return buf, err
+	}
+	extra, _ := br.Peek(br.Buffered())
+
+	return append(buf, extra...), nil
+}
+
+// Same as readHandshake but the whole packet is read whatever its protocol_version when
+// opts.AcceptAnyProtocolVersion is set, and no more than opts.MaxBytes are read when it's set
+func readHandshakeOptions(r io.Reader, opts DecodeOptions) ([]byte, error) {
+	limit := maxHandshakeSize
+	if opts.MaxBytes > 0 {
+		limit = opts.MaxBytes
+	}
+
+	// header(4) and protocol_version(1)
+	buf := make([]byte, min(5, limit))
+	n, err := io.ReadFull(r, buf)
+	if resetErr := connectionReset(err); resetErr != nil {
+		return nil, resetErr
+	}
+	if n == 0 {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrorConnectionClosed
+		}
+		return nil, err
+	}
+	// Let Decode report what's missing from a partial packet
+	if err != nil || n < 5 {
+		return buf[:n], nil
+	}
+
+	switch buf[4] {
+	case 9, 10, errPacketHeader:
+	default:
+		if !opts.AcceptAnyProtocolVersion {
+			return buf, nil
+		}
+	}
+
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+	size := min(pktLen+4, limit)
+	if size <= len(buf) {
+		return buf[:size], nil
+	}
+	buf = append(buf, make([]byte, size-len(buf))...)
+	n, err = io.ReadFull(r, buf[5:])
+	if resetErr := connectionReset(err); resetErr != nil {
+		return nil, resetErr
+	}
+
+	return buf[:5+n], nil
+}
+
+// err wrapped in ErrorConnectionReset when it's a reset, nil for any other error
+// What arrived before a reset isn't decoded as a short packet, the whole read fails
+func connectionReset(err error) error {
+	if errors.Is(err, syscall.ECONNRESET) {
+		return fmt.Errorf("%w: %w", ErrorConnectionReset, err)
+	}
+
+	return nil
+}
+
+// Read from r into a buffer of size bytes until done says buf holds enough, the buffer is full, or r errors
+func readPacket(r io.Reader, size int, done func(buf []byte) bool) ([]byte, error) {
+	buf := make([]byte, size)
+	n := 0
+	for n < len(buf) {
+		read, err := r.Read(buf[n:])
+		n += read
+
+		if done(buf[:n]) {
+			break
+		}
+
+		if err != nil {
+			if resetErr := connectionReset(err); resetErr != nil {
+				return nil, resetErr
+			}
+			// Let Decode report what's missing from a partial packet
+			if n > 0 {
+				break
+			}
+			if errors.Is(err, io.EOF) {
+				return nil, ErrorConnectionClosed
+			}
+			return nil, err
+		}
+	}
+
+	return buf[:n], nil
+}
+
+// Whether buf holds the whole packet its length prefix describes
+func packetComplete(buf []byte) bool {
+	if len(buf) < 4 {
+		return false
+	}
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+
+	return pktLen+4 <= len(buf)
+}
+
+// Prefer the context error when the context ended, so callers can check for context.Canceled
+// rather than whatever error the connection happened to return
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// String output to a human readable form
+func (s *MySQLv10) String() string {
+	// Nothing past the server version was decoded, so the rest would only be misleading zeros
+	if s.BannerOnly {
+		fields := []string{
+			fmt.Sprintf("ProtocolVersion:%d", s.ProtocolVersion),
+			fmt.Sprintf("ServerVersion:%s", s.ServerVersion),
+			fmt.Sprintf("Flavor:%s", s.Flavor()),
+		}
+		if s.Latency != 0 {
+			fields = append(fields, fmt.Sprintf("Latency:%s", s.Latency))
+		}
+		return "{" + strings.Join(fields, " ") + "}"
+	}
+
+	fields := []string{
+		fmt.Sprintf("ProtocolVersion:%d", s.ProtocolVersion),
+		fmt.Sprintf("ServerVersion:%s", s.ServerVersion),
+		fmt.Sprintf("Flavor:%s", s.Flavor()),
+		fmt.Sprintf("ConnectionId:%d", s.ConnectionId),
+		fmt.Sprintf("CharacterSet:%s", s.CharacterSetName()),
+		fmt.Sprintf("Status:%d(%s)", s.Status, s.statusNames()),
+		fmt.Sprintf("Capabilities:%#08x(%s)", s.Capabilities, CapabilityNames(s.Capabilities)),
+		fmt.Sprintf("SupportsTLS:%t", s.SupportsTLS()),
+		fmt.Sprintf("TLSPosture:%s", s.TLSPosture()),
+		fmt.Sprintf("DeprecateEOF:%t", s.DeprecateEOF()),
+		fmt.Sprintf("Protocol41:%t", s.Protocol41),
+		fmt.Sprintf("AuthPlugin:%s", s.AuthPlugin),
+		fmt.Sprintf("AuthPluginSecurity:%s", s.AuthPluginSecurity()),
+		fmt.Sprintf("CachingSHA2:%t", s.CachingSHA2),
+		fmt.Sprintf("AuthData:%s(%d bytes)", s.AuthDataHex(), s.ScrambleLength()),
+		fmt.Sprintf("Fingerprint:%s", s.Fingerprint()),
+	}
+	if s.IsEndOfLife() {
+		eol, _ := s.EndOfLife()
+		fields = append(fields, fmt.Sprintf("EndOfLife:%s", eol.Format(time.DateOnly)))
+	}
+	if likely, proxy := s.LikelyProxy(); likely {
+		fields = append(fields, fmt.Sprintf("Proxy:%s", proxy))
+	}
+	if s.TLS != nil {
+		fields = append(fields, fmt.Sprintf("TLS:%s", s.TLS))
+	}
+	if len(s.Extra) > 0 {
+		fields = append(fields, fmt.Sprintf("Extra:%x", s.Extra))
+	}
+	if s.Latency != 0 {
+		fields = append(fields, fmt.Sprintf("Latency:%s", s.Latency))
+	}
+	if warnings := s.Warnings(); len(warnings) > 0 {
+		fields = append(fields, fmt.Sprintf("Warnings:[%s]", strings.Join(warnings, "; ")))
+	}
+
+	return "{" + strings.Join(fields, " ") + "}"
+}
+
+// Warnings about the handshake that affect how far its decoded values can be trusted
+func (s *MySQLv10) Warnings() []string {
+	var warnings []string
+	if !s.Protocol41 && !s.BannerOnly {
+		warnings = append(warnings, "CLIENT_PROTOCOL_41 not set, pre-4.1 server so auth data may be unreliable")
+	}
+	if s.ForcedDecode {
+		warnings = append(warnings, fmt.Sprintf("Protocol version %d isn't 10, decoded as a v10 handshake on a best effort basis", s.ProtocolVersion))
+	}
+	if s.LowConnectionId() {
+		warnings = append(warnings, fmt.Sprintf("Connection id %d is low, the server was recently restarted or is a honeypot", s.ConnectionId))
+	}
+	if len(s.Extra) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d bytes followed the handshake, possibly a proxy or wrapper in front of the server", len(s.Extra)))
+	}
+	if s.NonZeroReserved {
+		warnings = append(warnings, "Reserved bytes in the handshake aren't zero, possibly a honeypot or fake server")
+	}
+	if s.WeakScramble {
+		warnings = append(warnings, "Auth data is a repeating pattern rather than random, possibly a honeypot replaying a fake scramble")
+	}
+	if s.Suspicious {
+		warnings = append(warnings, "auth_plugin_data_len doesn't match the auth data sent, possibly a honeypot or broken server")
+	}
+	if s.AuthPluginSecurity() == AuthPluginInsecure {
+		warnings = append(warnings, "Default auth plugin is mysql_old_password, the pre-4.1 hash is insecure")
+	}
+
+	return warnings
+}
+
+// Connection IDs below this are low enough that hardly anything has connected since the server started
+const lowConnectionId = 10
+
+// LowConnectionId is a hint that the server was recently (re)started, or is a honeypot that resets its counter,
+// since the connection ID counts every connection made since it started
+func (s *MySQLv10) LowConnectionId() bool {
+	return !s.BannerOnly && s.ConnectionId < lowConnectionId
+}
+
+// AuthDataHex is AuthData as a lowercase hex string
+func (s *MySQLv10) AuthDataHex() string {
+	return hex.EncodeToString(s.AuthData)
+}
+
+// ScrambleLength is the number of bytes of AuthData the server sent
+// A normal mysql_native_password or caching_sha2_password scramble is 20 bytes, old servers only send 8
+func (s *MySQLv10) ScrambleLength() int {
+	return len(s.AuthData)
+}
+
+// MarshalJSON encodes the handshake with AuthData and its parts as hex strings rather than base64
+// so it can be compared by eye against packet captures, along with the detected flavor
+// Latency is written as a duration string such as 1.5ms
+func (s *MySQLv10) MarshalJSON() ([]byte, error) {
+	type alias MySQLv10
+	out := struct {
+		*alias
+		AuthData       string   `json:"auth_data"`
+		AuthDataPart1  string   `json:"auth_data_part1"`
+		AuthDataPart2  string   `json:"auth_data_part2"`
+		ScrambleLength int      `json:"scramble_length"`
+		Flavor         string   `json:"flavor"`
+		EndOfLife      bool     `json:"end_of_life"`
+		AuthSecurity   string   `json:"auth_plugin_security"`
+		TLSPosture     string   `json:"tls_posture"`
+		DeprecateEOF   bool     `json:"deprecate_eof"`
+		LowConnection  bool     `json:"low_connection_id"`
+		Fingerprint    string   `json:"fingerprint,omitempty"`
+		Proxy          string   `json:"proxy,omitempty"`
+		Latency        string   `json:"latency,omitempty"`
+		Warnings       []string `json:"warnings,omitempty"`
+		Raw            string   `json:"raw,omitempty"`
+		Extra          string   `json:"extra,omitempty"`
+	}{
+		alias:          (*alias)(s),
+		AuthData:       s.AuthDataHex(),
+		AuthDataPart1:  hex.EncodeToString(s.AuthDataPart1),
+		AuthDataPart2:  hex.EncodeToString(s.AuthDataPart2),
+		ScrambleLength: s.ScrambleLength(),
+		Flavor:         s.Flavor(),
+		EndOfLife:      s.IsEndOfLife(),
+		AuthSecurity:   s.AuthPluginSecurity(),
+		TLSPosture:     s.TLSPosture(),
+		DeprecateEOF:   s.DeprecateEOF(),
+		LowConnection:  s.LowConnectionId(),
+		Warnings:       s.Warnings(),
+		Raw:            hex.EncodeToString(s.RawPacket),
+		Extra:          hex.EncodeToString(s.Extra),
+	}
+	if s.Latency != 0 {
+		out.Latency = s.Latency.String()
+	}
+	_, out.Proxy = s.LikelyProxy()
+	// Only the server version was decoded, so there is nothing to fingerprint
+	if !s.BannerOnly {
+		out.Fingerprint = s.Fingerprint()
+	}
+
+	return json.Marshal(&out)
+}
+
+// Decode the handshake packet given the byte slice
+// Handshake packet described here:
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::Handshake
+//
+// Another usage reference in connector.go:
+// https://github.com/go-sql-driver/mysql
+//
+// If the server sent an ERR packet instead, the returned error is an *ErrPacket with the server's reason
+// A malformed or truncated handshake is a *DecodeError saying which field couldn't be decoded
+func (s *MySQLv10) Decode(buf []byte) error {
+	return s.DecodeWithOptions(buf, DecodeOptions{})
+}
+
+// DecodeN is Decode also returning the number of bytes of buf the packet took up, its length plus the 4 byte
+// header, so a buffer holding several packets can be walked by decoding buf[n:] next
+// Nothing is consumed when decoding fails
+func (s *MySQLv10) DecodeN(buf []byte) (int, error) {
+	if err := s.Decode(buf); err != nil {
+		return 0, err
+	}
+
+	return len(s.RawPacket), nil
+}
+
+// DecodeOptions relax the checks Decode makes before trusting a packet to be a handshake
+type DecodeOptions struct {
+	// AcceptAnyProtocolVersion decodes the packet as a v10 handshake whatever its protocol_version,
+	// for researching forks and honeypots that send unusual values. ForcedDecode is set when it was needed
+	AcceptAnyProtocolVersion bool
+
+	// MaxBytes caps how many bytes are read from the server, for scanning untrusted ranges where a server
+	// might stream data indefinitely. Whatever arrived up to the cap is decoded, 0 reads a whole handshake up
+	// to 64KiB, or the first 1KiB for a banner
+	MaxBytes int
+
+	// Trace is called with each field as it's decoded, for debugging handshakes that won't decode
+	// If decoding fails it's called one last time with Err set and Offset where the failing field starts
+	Trace func(step DecodeStep)
+}
+
+// DecodeStep is a field of the handshake as it was decoded, passed to DecodeOptions.Trace
+type DecodeStep struct {
+	// Field is the name of the field in the handshake doc, such as server_version
+	Field string
+
+	// Offset of the field's first byte in the packet, which starts with the 4 byte header
+	Offset int
+
+	// Raw bytes of the field, including any null terminator
+	Raw []byte
+
+	// Value the field decoded to
+	Value any
+
+	// Err is only set on the step reporting that decoding failed, with no Field, Raw or Value
+	Err error
+}
+
+// Calls DecodeOptions.Trace for each decoded field, keeping track of where the next field starts
+type decodeTracer struct {
+	trace func(step DecodeStep)
+	buf   []byte
+	next  int
+}
+
+// Decoding the field starting at offset failed because of err, which is returned as a *DecodeError
+func (t *decodeTracer) fail(name string, offset int, err error) error {
+	t.next = offset
+	return &DecodeError{Offset: offset, Field: name, Err: err}
+}
+
+// Record the field in buf[start:end] decoded to value
+func (t *decodeTracer) field(name string, start, end int, value any) {
+	t.next = end
+	if t.trace != nil {
+		t.trace(DecodeStep{Field: name, Offset: start, Raw: t.buf[start:end:end], Value: value})
+	}
+}
+
+// DecodeWithOptions is Decode with the checks relaxed by opts
+func (s *MySQLv10) DecodeWithOptions(buf []byte, opts DecodeOptions) error {
+	t := &decodeTracer{trace: opts.Trace, buf: buf}
+	err := s.decode(buf, opts, t)
+	if err != nil && opts.Trace != nil {
+		opts.Trace(DecodeStep{Offset: t.next, Err: err})
+	}
+
+	return err
+}
+
+// Decode buf into s, reporting each field to t
+func (s *MySQLv10) decode(buf []byte, opts DecodeOptions, t *decodeTracer) error {
+	// Fields below are only set when the packet has them, start from nothing so a reused MySQLv10 can't
+	// carry one server's values over to the next, even when decoding fails part way through
+	*s = MySQLv10{}
+
+	if len(buf) < 4 {
+		return t.fail("header", 0, ErrorMissingData)
+	}
+
+	// Servers refusing the connection send an ERR packet where the handshake would be
+	if len(buf) > 4 && buf[4] == errPacketHeader {
+		return decodeErrPacket(buf)
+	}
+
+	// Check the protocol_version byte before trusting the length, other protocols would decode
+	// to some arbitrary length and be reported as missing data rather than not being MySQL
+	if len(buf) > 4 && buf[4] != 9 && buf[4] != 10 && !opts.AcceptAnyProtocolVersion {
+		if isXProtocol(buf) {
+			return ErrorXProtocol
+		}
+		return ErrorNotMySQL
+	}
+
+	// First 3 bytes are the packet length of the handshake packet
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+
+	// The sequence byte counts packets within a command, the initial handshake is always the first packet
+	// Anything else means we joined mid-stream or the peer isn't sending a handshake
+	if buf[3] != 0 {
+		return t.fail("header", 0, ErrorUnexpectedSequence)
+	}
+
+	if pktLen+4 > len(buf) {
+		return t.fail("header", 0, ErrorMissingData)
+	}
+	t.field("header", 0, 4, pktLen)
+
+	// Only look at this packet, a short field followed by anything else in the buffer should be missing data.
+	// Every fixed size read below is checked against this since the server is untrusted and pktLen
+	// can claim more fields than are really there.
+	if len(buf) > pktLen+4 {
+		s.Extra = append([]byte{}, buf[pktLen+4:]...)
+	}
+	buf = buf[:pktLen+4]
+	s.RawPacket = append([]byte{}, buf...)
+
+	// Start using position variable to keep track of decoding
+	pos := 4
+	if pos >= len(buf) {
+		return t.fail("protocol_version", pos, ErrorMissingData)
+	}
+
+	// protocol_version(1) This is only meant to work with version 10
+	if 10 != buf[pos] {
+		if !opts.AcceptAnyProtocolVersion {
+			return t.fail("protocol_version", pos, ErrorInvalidProtocol)
+		}
+		s.ForcedDecode = true
+	}
+	s.ProtocolVersion = buf[pos]
+	t.field("protocol_version", pos, pos+1, s.ProtocolVersion)
+	pos += 1
+
+	// server_version(null terminated string)
+	// Without its terminator there is no telling where the version ends and the fields after it begin
+	end := bytes.IndexByte(buf[pos:], 0)
+	if end == -1 {
+		return t.fail("server_version", pos, ErrorMissingData)
+	}
+	s.ServerVersion = string(buf[pos : pos+end])
+	t.field("server_version", pos, pos+end+1, s.ServerVersion)
+	pos += end + 1 // Extra +1 for the null terminator
+
+	// connection_id(4)
+	if pos+4 > len(buf) {
+		return t.fail("connection_id", pos, ErrorMissingData)
+	}
+	s.ConnectionId = binary.LittleEndian.Uint32(buf[pos : pos+4])
+	t.field("connection_id", pos, pos+4, s.ConnectionId)
+	pos += 4
+
+	// auth_plugin_data_1(8) 8 byte string representing the first 8 bytes of auth-plugin data
+	// Capacity is capped so appending part 2 later can't overwrite the caller's buffer
+	if pos+8+1 > len(buf) {
+		return t.fail("auth_plugin_data_part_1", pos, ErrorMissingData)
+	}
+	authData := buf[pos : pos+8 : pos+8]
+	t.field("auth_plugin_data_part_1", pos, pos+8, hex.EncodeToString(authData))
+	t.field("filler", pos+8, pos+9, buf[pos+8])
+	pos += 8 + 1 // Extra +1 because of filler_1(1) which is just a zeroed byte
+
+	// capability_flag_1(2) lower two bytes of the capabilities flags
+	if pos+2 > len(buf) {
+		return t.fail("capability_flags_1", pos, ErrorMissingData)
+	}
+	s.Capabilities = uint32(binary.LittleEndian.Uint16(buf[pos : pos+2]))
+	t.field("capability_flags_1", pos, pos+2, fmt.Sprintf("%#04x", s.Capabilities))
+	pos += 2
+	s.Protocol41 = s.Capabilities&ClientProtocol41 != 0
+
+	// Before 4.1 the handshake ends with character_set(1) status_flags(2) and a zeroed filler, there is no
+	// capability_flags_2 or second part of the auth data so only the 4.1 layout is read as extended fields.
+	// Otherwise whatever is in the filler would be decoded as capabilities and auth data that aren't there
+	if !s.Protocol41 {
+		if pos+1+2 <= len(buf) {
+			s.CharacterSet = buf[pos]
+			t.field("character_set", pos, pos+1, s.CharacterSet)
+			s.Status = binary.LittleEndian.Uint16(buf[pos+1 : pos+3])
+			t.field("status_flags", pos+1, pos+3, fmt.Sprintf("%#04x", s.Status))
+		}
+	} else if pos < len(buf) {
+		// More data within the packet is the extended fields, whose fixed size fields up to the end of the
+		// reserved section are checked for first: character_set(1) status_flags(2) capability_flags_2(2)
+		// auth_data_plugin_len(1) reserved(10). They're optional, a handshake ending after capability_flags_1
+		// has no part 2 even with CLIENT_SECURE_CONNECTION set, so AuthData is just the 8 bytes of part 1
+		fixed := []struct {
+			name string
+			size int
+		}{{"character_set", 1}, {"status_flags", 2}, {"capability_flags_2", 2}, {"auth_plugin_data_len", 1}, {"reserved", 10}}
+		next := pos
+		for _, f := range fixed {
+			if next+f.size > len(buf) {
+				return t.fail(f.name, next, ErrorMissingData)
+			}
+			next += f.size
+		}
+
+		// character_set(1)
+		s.CharacterSet = buf[pos]
+		t.field("character_set", pos, pos+1, s.CharacterSet)
+		pos += 1
+
+		// status_flags(2) bit-fields representing status
+		s.Status = binary.LittleEndian.Uint16(buf[pos : pos+2])
+		t.field("status_flags", pos, pos+2, fmt.Sprintf("%#04x", s.Status))
+		pos += 2
+
+		// capability_flags_2(2) upper two bytes of the capabilities flags sometimes called extended capabilities
+		s.Capabilities |= uint32(binary.LittleEndian.Uint16(buf[pos:pos+2])) << 16
+		t.field("capability_flags_2", pos, pos+2, fmt.Sprintf("%#04x", s.Capabilities>>16))
+		pos += 2
+
+		// auth_data_plugin_len(1) Length of the second plugin data piece
+		authLen := -1
+		if s.Capabilities&ClientPluginAuth != 0 {
+			s.AuthDataLen = buf[pos]
+			authLen = int(s.AuthDataLen)
+		}
+		t.field("auth_plugin_data_len", pos, pos+1, buf[pos])
+		pos += 1
+
+		// reserved(10) should be zeroed out, except MariaDB puts its extended capabilities in the last 4 bytes
+		// when CLIENT_LONG_PASSWORD (CLIENT_MYSQL to MariaDB) isn't set
+		reserved := buf[pos : pos+10]
+		if s.Capabilities&ClientLongPassword == 0 {
+			reserved = reserved[:6]
+		}
+		s.NonZeroReserved = bytes.Count(reserved, []byte{0}) != len(reserved)
+		t.field("reserved", pos, pos+10, hex.EncodeToString(buf[pos:pos+10]))
+		pos += 10
+
+		// A real server's auth data is at least the 8 bytes of part 1, anything shorter is a hostile or broken server
+		if authLen != -1 && authLen < 8 {
+			s.Suspicious = true
+		}
+
+		if s.Capabilities&ClientSecureConnection != 0 {
+			// Remaining auth data length is described on dev.mysql.com as max(13, auth_data_plugin_len - 8)
+			authDataLen := 13
+			if authLen-8 > authDataLen {
+				authDataLen = authLen - 8
+			}
+
+			// Claiming more auth data than the packet holds is suspicious, fall back to the normal 13 bytes
+			// rather than reading into the plugin name or reporting the whole handshake as missing data
+			if pos+authDataLen > len(buf) && authDataLen > 13 {
+				s.Suspicious = true
+				authDataLen = 13
+			}
+			authDataLen -= 1 // Last byte is null so just remove it
+
+			// auth_plugin_data_part_2(authDataLen) second part of the cipher
+			if pos+authDataLen+1 > len(buf) {
+				return t.fail("auth_plugin_data_part_2", pos, ErrorMissingData)
+			}
+			authData = append(authData, buf[pos:pos+authDataLen]...)
+			t.field("auth_plugin_data_part_2", pos, pos+authDataLen+1, hex.EncodeToString(buf[pos:pos+authDataLen]))
+			pos += authDataLen + 1 // Add the null byte back
+		}
+
+		if s.Capabilities&ClientPluginAuth != 0 {
+			// auth_plugin_name(null terminated string) name of the auth method
+			s.AuthPlugin = read_cstr(buf[pos:])
+			t.field("auth_plugin_name", pos, min(pos+len(s.AuthPlugin)+1, len(buf)), s.AuthPlugin)
+		}
+	}
+
+	s.CachingSHA2 = s.AuthPlugin == authPluginCachingSHA2
+	s.AuthData = make([]byte, len(authData))
+	copy(s.AuthData, authData)
+	s.AuthDataPart1 = s.AuthData[:8:8]
+	if len(s.AuthData) > 8 {
+		s.AuthDataPart2 = s.AuthData[8:]
+	}
+	s.WeakScramble = weakScramble(s.AuthData)
+	return nil
+}
+
+// A random scramble of n bytes has close to n distinct bytes, one with fewer than half that or made of a
+// repeating pattern, which covers part 1 and part 2 being the same, wasn't randomly generated
+func weakScramble(data []byte) bool {
+	if len(data) == 0 {
+		return false
+	}
+
+	var seen [256]bool
+	distinct := 0
+	for _, b := range data {
+		if !seen[b] {
+			seen[b] = true
+			distinct++
+		}
+	}
+	if distinct*2 < len(data) {
+		return true
+	}
+
+	for period := 1; period <= len(data)/2; period++ {
+		if bytes.Equal(data[period:], data[:len(data)-period]) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Read a null terminated string from a byte slice, the whole slice when there is no null
+// Only used for the auth plugin name at the very end of the packet, which some servers don't terminate
+func read_cstr(buf []byte) string {
+	pos := bytes.IndexByte(buf, 0)
+	if pos == -1 {
+		return string(buf[:])
+	}
+
+	return string(buf[:pos])
+}
@@ -0,0 +1,1252 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// TODO: Test DetectMySQL Function
+
+func TestDetectMySQLIPv6(t *testing.T) {
+	ln, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback isn't available: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(normalHandshake)
+		conn.Close()
+	}()
+
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	host := net.JoinHostPort("::1", port)
+	if host != "[::1]:"+port {
+		t.Fatalf("Unexpected IPv6 host:port '%s'", host)
+	}
+
+	sql, err := DetectMySQL(host, 1)
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL on %s: %s", host, err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Server version didn't match expected: %s", sql.ServerVersion)
+	}
+}
+
+func TestDetectMySQLLatency(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(normalHandshake)
+		conn.Close()
+	}()
+
+	sql, err := DetectMySQL(ln.Addr().String(), 1)
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	if sql.Latency <= 0 {
+		t.Errorf("Expected latency to be recorded, got %s", sql.Latency)
+	}
+	if !strings.Contains(sql.String(), "Latency:") {
+		t.Errorf("String() didn't include the latency: %s", sql.String())
+	}
+
+	out, err := json.Marshal(sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"latency":"`+sql.Latency.String()+`"`) {
+		t.Errorf("JSON didn't include the latency: %s", out)
+	}
+}
+
+func TestDetectMySQLConnectionClosed(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Close()
+	}()
+
+	_, err = DetectMySQL(ln.Addr().String(), 1)
+	if !errors.Is(err, ErrorConnectionClosed) || !errors.Is(err, ErrorRead) {
+		t.Errorf("Expected ErrorConnectionClosed wrapped by ErrorRead, got: %v", err)
+	}
+}
+
+func TestDetectMySQLConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go func() {
+		server.Write(normalHandshake)
+		server.Close()
+	}()
+
+	sql, err := DetectMySQLConn(client, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL on the connection: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" || sql.AuthPlugin != "caching_sha2_password" {
+		t.Errorf("Handshake didn't match expected: %s", sql.String())
+	}
+
+	// Nothing sent, so the read deadline should end it
+	silent, silentServer := net.Pipe()
+	defer silentServer.Close()
+	if _, err := DetectMySQLConn(silent, 50*time.Millisecond); !errors.Is(err, ErrorRead) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected a read deadline error wrapped by ErrorRead, got: %v", err)
+	}
+}
+
+func TestDetectMySQLBareIPv6(t *testing.T) {
+	for _, host := range []string{"::1", "::1:3306", "fe80::1:3306"} {
+		if _, err := DetectMySQL(host, 1); !errors.Is(err, ErrorBareIPv6) {
+			t.Errorf("Expected ErrorBareIPv6 for '%s', got: %v", host, err)
+		}
+	}
+}
+
+func TestDetectMySQLContextCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := DetectMySQLContext(ctx, "127.0.0.1:3306"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected error wrapping context.Canceled, got: %v", err)
+	}
+}
+
+// TODO: Check parsed sql fields
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		err  bool
+	}{
+		{
+			name: "Normal v8.0.21", // Took this from a packet capture
+			buf: []byte{
+				0x4a, 0x00, 0x00, 0x00, 0x0a, 0x38, 0x2e, 0x30, 0x2e, 0x32, 0x31, 0x00, 0x10, 0x00, 0x00, 0x00,
+				0x38, 0x63, 0x7a, 0x7b, 0x5e, 0x07, 0x6a, 0x39, 0x00, 0xff, 0xff, 0xff, 0x02, 0x00, 0xff, 0xc7,
+				0x15, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x38, 0x35, 0x48, 0x50,
+				0x68, 0x4c, 0x5c, 0x62, 0x42, 0x0b, 0x4e, 0x00, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f,
+				0x73, 0x68, 0x61, 0x32, 0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x00,
+			},
+			err: false,
+		},
+		{
+			name: "No data",
+			buf:  []byte{},
+			err:  true,
+		},
+		{
+			name: "Missing packet data",
+			buf:  []byte{0x4a, 0x00, 0x00, 0x00}, // Packet length = 78
+			err:  true,
+		},
+		{
+			name: "Protocol version 9", // Modified packet capture (4th byte)
+			buf: []byte{
+				0x4a, 0x00, 0x00, 0x00, 0x09, 0x38, 0x2e, 0x30, 0x2e, 0x32, 0x31, 0x00, 0x10, 0x00, 0x00, 0x00,
+				0x38, 0x63, 0x7a, 0x7b, 0x5e, 0x07, 0x6a, 0x39, 0x00, 0xff, 0xff, 0xff, 0x02, 0x00, 0xff, 0xc7,
+				0x15, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x38, 0x35, 0x48, 0x50,
+				0x68, 0x4c, 0x5c, 0x62, 0x42, 0x0b, 0x4e, 0x00, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f,
+				0x73, 0x68, 0x61, 0x32, 0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x00,
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		err := sql.Decode(test.buf)
+		if (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s'", test.name)
+		}
+	}
+}
+
+// Packet capture of a MySQL v8.0.21 handshake, shared by tests that patch individual fields
+var normalHandshake = []byte{
+	0x4a, 0x00, 0x00, 0x00, 0x0a, 0x38, 0x2e, 0x30, 0x2e, 0x32, 0x31, 0x00, 0x10, 0x00, 0x00, 0x00,
+	0x38, 0x63, 0x7a, 0x7b, 0x5e, 0x07, 0x6a, 0x39, 0x00, 0xff, 0xff, 0xff, 0x02, 0x00, 0xff, 0xc7,
+	0x15, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x38, 0x35, 0x48, 0x50,
+	0x68, 0x4c, 0x5c, 0x62, 0x42, 0x0b, 0x4e, 0x00, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f,
+	0x73, 0x68, 0x61, 0x32, 0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x00,
+}
+
+// Copy of normalHandshake with the bytes starting at offset replaced
+func patchHandshake(offset int, b ...byte) []byte {
+	buf := make([]byte, len(normalHandshake))
+	copy(buf, normalHandshake)
+	copy(buf[offset:], b)
+	return buf
+}
+
+// Reader that only returns a couple of bytes per Read to simulate a fragmented connection
+type dribbleReader struct {
+	buf []byte
+}
+
+func (r *dribbleReader) Read(p []byte) (int, error) {
+	if len(r.buf) == 0 {
+		return 0, io.EOF
+	}
+
+	n := copy(p[:min(len(p), 2)], r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+func TestReadHandshakeFragmented(t *testing.T) {
+	// Trailing bytes after the packet shouldn't be waited on
+	r := &dribbleReader{buf: append(append([]byte{}, normalHandshake...), 0xff, 0xff, 0xff)}
+	buf, err := readHandshake(r)
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %s", err)
+	}
+	if !bytes.Equal(buf, normalHandshake) {
+		t.Errorf("Read handshake didn't match the packet sent: %x", buf)
+	}
+
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Errorf("Failed to decode fragmented handshake: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Server version didn't match expected: %s", sql.ServerVersion)
+	}
+}
+
+func TestReadHandshakeReset(t *testing.T) {
+	reset := os.NewSyscallError("read", syscall.ECONNRESET)
+
+	// Reset partway through the handshake, what arrived isn't decoded as a short packet
+	r := io.MultiReader(bytes.NewReader(normalHandshake[:20]), iotest.ErrReader(reset))
+	if _, err := readHandshake(r); !errors.Is(err, ErrorConnectionReset) || !errors.Is(err, syscall.ECONNRESET) {
+		t.Errorf("Expected ErrorConnectionReset partway through, got: %v", err)
+	}
+
+	// And before anything arrived
+	if _, err := readHandshake(iotest.ErrReader(reset)); !errors.Is(err, ErrorConnectionReset) {
+		t.Errorf("Expected ErrorConnectionReset before the handshake, got: %v", err)
+	}
+}
+
+func TestReadHandshakeMaxBytes(t *testing.T) {
+	// A server claiming the largest packet length then streaming 64KiB of junk after the version
+	stream := append([]byte{0xff, 0xff, 0xff, 0x00, 0x0a}, bytes.Repeat([]byte{'x'}, 64*1024)...)
+
+	r := &dribbleReader{buf: stream}
+	buf, err := readHandshakeOptions(r, DecodeOptions{MaxBytes: 100})
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %s", err)
+	}
+	if read := len(stream) - len(r.buf); len(buf) != 100 || read != 100 {
+		t.Errorf("Expected only 100 bytes to be read, read %d and returned %d", read, len(buf))
+	}
+
+	// Whatever was read is still decoded, here the server version never ends
+	if _, err := DetectMySQLOptions(context.Background(), &fakeDialer{packet: stream}, "db.internal:3306", DecodeOptions{MaxBytes: 100}); !errors.Is(err, ErrorMissingData) {
+		t.Errorf("Expected ErrorMissingData for a capped read, got: %v", err)
+	}
+
+	// Fewer bytes than the header are still returned for Decode to report
+	r = &dribbleReader{buf: stream}
+	if buf, err := readHandshakeOptions(r, DecodeOptions{MaxBytes: 3}); err != nil || len(buf) != 3 {
+		t.Errorf("Expected 3 bytes, got %d: %v", len(buf), err)
+	}
+
+	// A banner only needs the start of the packet, so a cap past the server version still detects it
+	banner := append(append([]byte{}, normalHandshake[:12]...), stream[5:]...)
+	sql, err := DetectMySQLBannerOptions(context.Background(), &fakeDialer{packet: banner}, "db.internal:3306", DecodeOptions{MaxBytes: 16})
+	if err != nil {
+		t.Fatalf("Failed to detect banner: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Banner didn't decode: %s", sql.String())
+	}
+}
+
+func TestReadHandshakeLarge(t *testing.T) {
+	// Well past the old fixed 1024 byte buffer
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	sql.ServerVersion = "8.0.21-" + strings.Repeat("x", 1500)
+	packet, err := sql.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode handshake: %s", err)
+	}
+
+	// Trailing bytes past the packet length shouldn't be read
+	buf, err := readHandshake(&dribbleReader{buf: append(append([]byte{}, packet...), 0xff)})
+	if err != nil {
+		t.Fatalf("Failed to read handshake: %s", err)
+	}
+	if !bytes.Equal(buf, packet) {
+		t.Errorf("Read %d bytes, expected the %d byte packet", len(buf), len(packet))
+	}
+
+	detected, err := DetectMySQLDialer(context.Background(), &fakeDialer{packet: packet}, "db.internal:3306")
+	if err != nil {
+		t.Fatalf("Failed to detect large handshake: %s", err)
+	}
+	if detected.ServerVersion != sql.ServerVersion || detected.AuthPlugin != "caching_sha2_password" {
+		t.Errorf("Large handshake didn't decode: %s", detected.String())
+	}
+}
+
+func TestCapabilityConstants(t *testing.T) {
+	tests := []struct {
+		name  string
+		flag  uint32
+		value uint32
+	}{
+		{name: "CLIENT_LONG_PASSWORD", flag: ClientLongPassword, value: 0x00000001},
+		{name: "CLIENT_PROTOCOL_41", flag: ClientProtocol41, value: 0x00000200},
+		{name: "CLIENT_SSL", flag: ClientSSL, value: 0x00000800},
+		{name: "CLIENT_SECURE_CONNECTION", flag: ClientSecureConnection, value: 0x00008000},
+		{name: "CLIENT_PLUGIN_AUTH", flag: ClientPluginAuth, value: 0x00080000},
+		{name: "CLIENT_DEPRECATE_EOF", flag: ClientDeprecateEOF, value: 0x01000000},
+	}
+
+	for _, test := range tests {
+		if test.flag != test.value {
+			t.Errorf("%s didn't match the documented value %#08x: %#08x", test.name, test.value, test.flag)
+		}
+	}
+}
+
+func TestCapabilityFlags(t *testing.T) {
+	// capability_flags_1 is at offset 25 and capability_flags_2 at offset 30
+	// LONG_PASSWORD|PROTOCOL_41|SSL|TRANSACTIONS|SECURE_CONNECTION = 0xaa01
+	// MULTI_STATEMENTS|PLUGIN_AUTH|DEPRECATE_EOF = 0x0109 << 16
+	buf := patchHandshake(25, 0x01, 0xaa)
+	buf[30], buf[31] = 0x09, 0x01
+
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	expected := CapabilitySet{
+		LongPassword:     true,
+		Protocol41:       true,
+		SSL:              true,
+		Transactions:     true,
+		SecureConnection: true,
+		MultiStatements:  true,
+		PluginAuth:       true,
+		DeprecateEOF:     true,
+	}
+	if caps := sql.CapabilityFlags(); caps != expected {
+		t.Errorf("Capability flags didn't match expected\ngot:  %+v\nwant: %+v", caps, expected)
+	}
+
+	names := "Capabilities:0x0109aa01(CLIENT_LONG_PASSWORD|CLIENT_PROTOCOL_41|CLIENT_SSL|CLIENT_TRANSACTIONS|CLIENT_SECURE_CONNECTION|CLIENT_MULTI_STATEMENTS|CLIENT_PLUGIN_AUTH|CLIENT_DEPRECATE_EOF) "
+	if !strings.Contains(sql.String(), names) {
+		t.Errorf("String() didn't contain the capabilities as hex and flag names: %s", sql.String())
+	}
+
+	// Bits without a CLIENT_* constant are still reported
+	sql.Capabilities = ClientProtocol41 | 0x04000000
+	if names := CapabilityNames(sql.Capabilities); names != "CLIENT_PROTOCOL_41|0x04000000" {
+		t.Errorf("Unnamed capability bits weren't reported: %s", names)
+	}
+}
+
+func TestStatusFlags(t *testing.T) {
+	// status_flags is at offset 28, IN_TRANS|AUTOCOMMIT|CURSOR_EXISTS|SESSION_STATE_CHANGED = 0x4043
+	sql := MySQLv10{}
+	if err := sql.Decode(patchHandshake(28, 0x43, 0x40)); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	expected := StatusSet{
+		InTransaction:       true,
+		Autocommit:          true,
+		CursorExists:        true,
+		SessionStateChanged: true,
+	}
+	if status := sql.StatusFlags(); status != expected {
+		t.Errorf("Status flags didn't match expected\ngot:  %+v\nwant: %+v", status, expected)
+	}
+
+	names := "SERVER_STATUS_IN_TRANS|SERVER_STATUS_AUTOCOMMIT|SERVER_STATUS_CURSOR_EXISTS|SERVER_SESSION_STATE_CHANGED"
+	if !strings.Contains(sql.String(), names) {
+		t.Errorf("String() didn't contain status flag names: %s", sql.String())
+	}
+}
+
+func TestCharacterSetName(t *testing.T) {
+	tests := []struct {
+		id   uint8
+		name string
+	}{
+		{id: 8, name: "latin1_swedish_ci"},
+		{id: 33, name: "utf8_general_ci"},
+		{id: 45, name: "utf8mb4_general_ci"},
+		{id: 63, name: "binary"},
+		{id: 255, name: "utf8mb4_0900_ai_ci"},
+		{id: 254, name: "unknown(254)"},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{CharacterSet: test.id}
+		if name := sql.CharacterSetName(); name != test.name {
+			t.Errorf("Character set %d name didn't match expected '%s': %s", test.id, test.name, name)
+		}
+	}
+}
+
+func TestDecodeNotMySQL(t *testing.T) {
+	sql := MySQLv10{}
+	err := sql.Decode([]byte("SSH-2.0-OpenSSH_8.9p1 Ubuntu-3ubuntu0.1\r\n"))
+	if !errors.Is(err, ErrorNotMySQL) {
+		t.Errorf("Expected ErrorNotMySQL for an SSH banner, got: %v", err)
+	}
+
+	// MySQL 8 X Protocol sends a notice frame on connect, 4 byte length then message type 11
+	err = sql.Decode([]byte{0x05, 0x00, 0x00, 0x00, 0x0b, 0x08, 0x05, 0x1a, 0x00})
+	if !errors.Is(err, ErrorNotMySQL) {
+		t.Errorf("Expected ErrorNotMySQL for an X Protocol notice, got: %v", err)
+	}
+
+	// Protocol version 9 is still MySQL, just not a version that can be decoded
+	err = sql.Decode(patchHandshake(4, 0x09))
+	if !errors.Is(err, ErrorInvalidProtocol) {
+		t.Errorf("Expected ErrorInvalidProtocol for protocol version 9, got: %v", err)
+	}
+}
+
+func TestDecodeAcceptAnyProtocolVersion(t *testing.T) {
+	buf := patchHandshake(4, 11)
+
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); !errors.Is(err, ErrorNotMySQL) {
+		t.Errorf("Expected ErrorNotMySQL for protocol version 11 without the override, got: %v", err)
+	}
+
+	sql = MySQLv10{}
+	if err := sql.DecodeWithOptions(buf, DecodeOptions{AcceptAnyProtocolVersion: true}); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.ProtocolVersion != 11 || !sql.ForcedDecode || sql.ServerVersion != "8.0.21" || sql.AuthPlugin != "caching_sha2_password" {
+		t.Errorf("Forced decode didn't match expected: %s", sql.String())
+	}
+	if !strings.Contains(sql.String(), "Protocol version 11 isn't 10") {
+		t.Errorf("String() didn't warn about the forced decode: %s", sql.String())
+	}
+
+	// The whole packet has to be read for the decode to work
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(buf)
+		conn.Close()
+	}()
+
+	detected, err := DetectMySQLOptions(context.Background(), nil, ln.Addr().String(), DecodeOptions{AcceptAnyProtocolVersion: true})
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	if detected.ProtocolVersion != 11 || detected.AuthPlugin != "caching_sha2_password" {
+		t.Errorf("Forced detect didn't match expected: %s", detected.String())
+	}
+}
+
+func TestLowConnectionId(t *testing.T) {
+	tests := []struct {
+		name         string
+		connectionId []byte
+		low          bool
+	}{
+		{name: "Recently started", connectionId: []byte{0x03, 0x00, 0x00, 0x00}, low: true},
+		{name: "Long running", connectionId: []byte{0x50, 0xc3, 0x00, 0x00}, low: false},
+	}
+
+	for _, test := range tests {
+		// connection_id follows server_version at offset 12
+		sql := MySQLv10{}
+		if err := sql.Decode(patchHandshake(12, test.connectionId...)); err != nil {
+			t.Fatalf("%s: failed to decode handshake: %s", test.name, err)
+		}
+		if sql.LowConnectionId() != test.low {
+			t.Errorf("%s: LowConnectionId didn't match expected %t for connection id %d", test.name, test.low, sql.ConnectionId)
+		}
+		if !strings.Contains(sql.String(), fmt.Sprintf("ConnectionId:%d ", sql.ConnectionId)) {
+			t.Errorf("%s: String() didn't include the connection id: %s", test.name, sql.String())
+		}
+
+		out, err := json.Marshal(&sql)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal JSON: %s", test.name, err)
+		}
+		expected := fmt.Sprintf(`"connection_id":%d,`, sql.ConnectionId)
+		if !strings.Contains(string(out), expected) || !strings.Contains(string(out), fmt.Sprintf(`"low_connection_id":%t`, test.low)) {
+			t.Errorf("%s: JSON didn't include the connection id: %s", test.name, out)
+		}
+	}
+}
+
+func TestAuthDataLen(t *testing.T) {
+	tests := []struct {
+		name     string
+		authLen  byte
+		authData int
+	}{
+		{name: "Matching", authLen: 0x15, authData: 20},
+		// Claims more than the packet holds, the decode falls back to the normal 20 bytes
+		{name: "Overstated", authLen: 0x40, authData: 20},
+	}
+
+	for _, test := range tests {
+		// auth_plugin_data_len follows capability_flags_2 at offset 32
+		sql := MySQLv10{}
+		if err := sql.Decode(patchHandshake(32, test.authLen)); err != nil {
+			t.Fatalf("%s: failed to decode handshake: %s", test.name, err)
+		}
+		if sql.AuthDataLen != test.authLen || len(sql.AuthData) != test.authData {
+			t.Errorf("%s: expected AuthDataLen %d with %d bytes of auth data, got %d with %d bytes", test.name, test.authLen, test.authData, sql.AuthDataLen, len(sql.AuthData))
+		}
+	}
+}
+
+func TestNonZeroReserved(t *testing.T) {
+	tests := []struct {
+		name    string
+		buf     []byte
+		nonZero bool
+	}{
+		{name: "Zeroed", buf: normalHandshake, nonZero: false},
+		// reserved(10) follows auth_plugin_data_len at offset 33
+		{name: "Non-zero", buf: patchHandshake(33, 0x00, 0x00, 0x2a), nonZero: true},
+		// MariaDB extended capabilities in the last 4 bytes, without CLIENT_LONG_PASSWORD (0x0001) at offset 25
+		{name: "MariaDB capabilities", buf: func() []byte {
+			buf := patchHandshake(39, 0x1d, 0x00, 0x00, 0x00)
+			buf[25] &^= 0x01
+			return buf
+		}(), nonZero: false},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		if err := sql.Decode(test.buf); err != nil {
+			t.Fatalf("%s: failed to decode handshake: %s", test.name, err)
+		}
+		if sql.NonZeroReserved != test.nonZero {
+			t.Errorf("%s: NonZeroReserved didn't match expected %t", test.name, test.nonZero)
+		}
+		if sql.ServerVersion != "8.0.21" || sql.AuthPlugin != "caching_sha2_password" {
+			t.Errorf("%s: handshake didn't decode: %s", test.name, sql.String())
+		}
+	}
+}
+
+func TestAuthDataParts(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	// auth_plugin_data_part_1 is the 8 bytes at offset 16, part 2 the 12 bytes at offset 43 before its null
+	if !bytes.Equal(sql.AuthDataPart1, normalHandshake[16:24]) {
+		t.Errorf("AuthDataPart1 wasn't the first 8 bytes: %x", sql.AuthDataPart1)
+	}
+	if !bytes.Equal(sql.AuthDataPart2, normalHandshake[43:55]) {
+		t.Errorf("AuthDataPart2 didn't match the packet capture: %x", sql.AuthDataPart2)
+	}
+	if combined := append(append([]byte{}, sql.AuthDataPart1...), sql.AuthDataPart2...); !bytes.Equal(combined, sql.AuthData) {
+		t.Errorf("AuthData wasn't part 1 followed by part 2: %x != %x", sql.AuthData, combined)
+	}
+
+	out, err := json.Marshal(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	expected := fmt.Sprintf(`"auth_data_part1":"%x","auth_data_part2":"%x"`, sql.AuthDataPart1, sql.AuthDataPart2)
+	if !strings.Contains(string(out), expected) {
+		t.Errorf("JSON didn't include the auth data parts as hex: %s", out)
+	}
+
+	// Without CLIENT_SECURE_CONNECTION (0x8000) at offset 25 there is only part 1
+	buf := append([]byte{}, normalHandshake...)
+	buf[26] &^= 0x80
+	pre := MySQLv10{}
+	if err := pre.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !bytes.Equal(pre.AuthDataPart1, normalHandshake[16:24]) || pre.AuthDataPart2 != nil {
+		t.Errorf("Expected only part 1 without CLIENT_SECURE_CONNECTION: %x %x", pre.AuthDataPart1, pre.AuthDataPart2)
+	}
+}
+
+func TestWeakScramble(t *testing.T) {
+	// Handshake with its 20 byte scramble replaced, part 1 is at offset 16 and part 2 at offset 43
+	withScramble := func(scramble []byte) []byte {
+		buf := patchHandshake(16, scramble[:8]...)
+		copy(buf[43:55], scramble[8:])
+		return buf
+	}
+	random := make([]byte, 20)
+	rand.NewChaCha8([32]byte{}).Read(random)
+	repeated := []byte("abcdefghabcdefghabcd")
+
+	tests := []struct {
+		name     string
+		scramble []byte
+		weak     bool
+	}{
+		{name: "All zero", scramble: make([]byte, 20), weak: true},
+		{name: "Part 1 repeated", scramble: repeated, weak: true},
+		{name: "Random", scramble: random, weak: false},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		if err := sql.Decode(withScramble(test.scramble)); err != nil {
+			t.Fatalf("%s: failed to decode handshake: %s", test.name, err)
+		}
+		if !bytes.Equal(sql.AuthData, test.scramble) {
+			t.Fatalf("%s: auth data didn't decode: %x", test.name, sql.AuthData)
+		}
+		if sql.WeakScramble != test.weak {
+			t.Errorf("%s: WeakScramble didn't match expected %t", test.name, test.weak)
+		}
+	}
+
+	// The packet capture's scramble came from a real server
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.WeakScramble {
+		t.Errorf("Real scramble flagged as weak: %s", sql.AuthDataHex())
+	}
+}
+
+func TestAuthDataHex(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	expected := hex.EncodeToString(sql.AuthData)
+	if expected != "38637a7b5e076a394538354850684c5c62420b4e" {
+		t.Errorf("Decoded auth data didn't match the packet capture: %s", expected)
+	}
+	if sql.AuthDataHex() != expected {
+		t.Errorf("AuthDataHex didn't match encoding/hex\ngot:  %s\nwant: %s", sql.AuthDataHex(), expected)
+	}
+	if sql.ScrambleLength() != 20 {
+		t.Errorf("Expected a 20 byte scramble, got %d", sql.ScrambleLength())
+	}
+	if !strings.Contains(sql.String(), "AuthData:"+expected+"(20 bytes)") {
+		t.Errorf("String() didn't render auth data as hex: %s", sql.String())
+	}
+}
+
+func TestSupportsTLS(t *testing.T) {
+	// CLIENT_SSL is bit 0x0800 of capability_flags_1 at offset 25
+	tests := []struct {
+		name string
+		buf  []byte
+		tls  bool
+	}{
+		{name: "SSL advertised", buf: patchHandshake(25, 0xff, 0xff), tls: true},
+		{name: "SSL not advertised", buf: patchHandshake(25, 0xff, 0xf7), tls: false},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		if err := sql.Decode(test.buf); err != nil {
+			t.Fatalf("Failed to decode handshake '%s': %s", test.name, err)
+		}
+		if sql.SupportsTLS() != test.tls {
+			t.Errorf("SupportsTLS didn't match expected '%s'", test.name)
+		}
+	}
+}
+
+func TestDeprecateEOF(t *testing.T) {
+	// CLIENT_DEPRECATE_EOF is bit 0x0100 of capability_flags_2 at offset 30
+	tests := []struct {
+		name       string
+		buf        []byte
+		deprecated bool
+	}{
+		{name: "DEPRECATE_EOF set", buf: patchHandshake(30, 0xff, 0xc7), deprecated: true},
+		{name: "DEPRECATE_EOF not set", buf: patchHandshake(30, 0xff, 0xc6), deprecated: false},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		if err := sql.Decode(test.buf); err != nil {
+			t.Fatalf("Failed to decode handshake '%s': %s", test.name, err)
+		}
+		if sql.DeprecateEOF() != test.deprecated {
+			t.Errorf("DeprecateEOF didn't match expected '%s'", test.name)
+		}
+		if !strings.Contains(sql.String(), fmt.Sprintf("DeprecateEOF:%t ", test.deprecated)) {
+			t.Errorf("String() didn't include DeprecateEOF '%s': %s", test.name, sql.String())
+		}
+
+		out, err := json.Marshal(&sql)
+		if err != nil {
+			t.Fatalf("Failed to marshal JSON '%s': %s", test.name, err)
+		}
+		if !strings.Contains(string(out), fmt.Sprintf(`"deprecate_eof":%t`, test.deprecated)) {
+			t.Errorf("JSON didn't include deprecate_eof '%s': %s", test.name, out)
+		}
+	}
+}
+
+func TestCachingSHA2(t *testing.T) {
+	// normalHandshake is from MySQL 8 offering caching_sha2_password
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.AuthPlugin != "caching_sha2_password" || !sql.CachingSHA2 {
+		t.Errorf("Expected CachingSHA2 for %s", sql.AuthPlugin)
+	}
+	if !strings.Contains(sql.String(), "CachingSHA2:true") {
+		t.Errorf("String didn't report CachingSHA2: %s", sql.String())
+	}
+	out, err := json.Marshal(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"caching_sha2":true`) {
+		t.Errorf("JSON didn't report caching_sha2: %s", out)
+	}
+
+	// Same length plugin name that isn't caching_sha2_password, decoded into the same struct
+	native := append(append([]byte{}, normalHandshake[:56]...), "mysql_native_password\x00"...)
+	if err := sql.Decode(native); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.AuthPlugin != "mysql_native_password" || sql.CachingSHA2 {
+		t.Errorf("Expected CachingSHA2 unset for %s", sql.AuthPlugin)
+	}
+}
+
+func TestTLSPosture(t *testing.T) {
+	tests := []struct {
+		name         string
+		capabilities uint32
+		posture      string
+	}{
+		{name: "SSL and secure connection", capabilities: ClientProtocol41 | ClientSSL | ClientSecureConnection, posture: TLSAdvertised},
+		{name: "SSL without secure connection", capabilities: ClientProtocol41 | ClientSSL, posture: TLSOptional},
+		{name: "No SSL", capabilities: ClientProtocol41 | ClientSecureConnection, posture: TLSNone},
+		{name: "Nothing", posture: TLSNone},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{Capabilities: test.capabilities}
+		if posture := sql.TLSPosture(); posture != test.posture {
+			t.Errorf("Posture didn't match expected '%s': %s", test.name, posture)
+		}
+	}
+
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !strings.Contains(sql.String(), "TLSPosture:advertised") {
+		t.Errorf("String() didn't include the TLS posture: %s", sql.String())
+	}
+}
+
+func TestRawPacket(t *testing.T) {
+	// Trailing bytes after the packet aren't part of it
+	buf := append(append([]byte{}, normalHandshake...), 0x01, 0x02)
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !bytes.Equal(sql.RawPacket, normalHandshake) {
+		t.Errorf("Raw packet didn't match the decoded packet: %x", sql.RawPacket)
+	}
+
+	// Must be a copy so a reused read buffer doesn't change the evidence
+	buf[5] = 'X'
+	if sql.RawPacket[5] != normalHandshake[5] {
+		t.Errorf("Raw packet aliases the decoded buffer")
+	}
+
+	out, err := json.Marshal(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"raw":"`+hex.EncodeToString(normalHandshake)+`"`) {
+		t.Errorf("JSON didn't include the raw packet: %s", out)
+	}
+
+	v9, err := decodeHandshake(v9Handshake)
+	if err != nil {
+		t.Fatalf("Failed to decode v9 handshake: %s", err)
+	}
+	if !bytes.Equal(v9.RawPacket, v9Handshake) {
+		t.Errorf("Raw packet didn't match the decoded v9 packet: %x", v9.RawPacket)
+	}
+}
+
+func TestExtra(t *testing.T) {
+	// A proxy greeting sent straight after the handshake
+	extra := []byte("\x05\x00\x00\x01hello")
+	buf := append(append([]byte{}, normalHandshake...), extra...)
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !bytes.Equal(sql.Extra, extra) {
+		t.Errorf("Extra didn't match the trailing bytes: %x", sql.Extra)
+	}
+	if !strings.Contains(sql.String(), "Extra:"+hex.EncodeToString(extra)) || !strings.Contains(sql.String(), "9 bytes followed the handshake") {
+		t.Errorf("String didn't report the extra bytes: %s", sql.String())
+	}
+	out, err := json.Marshal(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal JSON: %s", err)
+	}
+	if !strings.Contains(string(out), `"extra":"`+hex.EncodeToString(extra)+`"`) {
+		t.Errorf("JSON didn't include the extra bytes: %s", out)
+	}
+
+	// Sent along with the handshake over the network it's kept too
+	detected, err := DetectMySQLDialer(context.Background(), &fakeDialer{packet: buf}, "db.internal:3306")
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	if !bytes.Equal(detected.Extra, extra) {
+		t.Errorf("Extra wasn't kept from the connection: %x", detected.Extra)
+	}
+
+	// Decoding again without trailing bytes clears it
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.Extra != nil || strings.Contains(sql.String(), "Extra:") {
+		t.Errorf("Extra set without trailing bytes: %x", sql.Extra)
+	}
+}
+
+func TestDecodeN(t *testing.T) {
+	// Two handshakes back to back, the second from another connection
+	second := patchHandshake(12, 0x11)
+	buf := append(append([]byte{}, normalHandshake...), second...)
+
+	var ids []uint32
+	for len(buf) > 0 {
+		sql := MySQLv10{}
+		n, err := sql.DecodeN(buf)
+		if err != nil {
+			t.Fatalf("Failed to decode handshake: %s", err)
+		}
+		if pktLen := int(buf[0]) | int(buf[1])<<8 | int(buf[2])<<16; n != pktLen+4 {
+			t.Fatalf("Expected %d bytes consumed, got %d", pktLen+4, n)
+		}
+		ids = append(ids, sql.ConnectionId)
+		buf = buf[n:]
+	}
+	if len(ids) != 2 || ids[0] != 16 || ids[1] != 17 {
+		t.Errorf("Didn't decode both handshakes: %v", ids)
+	}
+
+	sql := MySQLv10{}
+	if n, err := sql.DecodeN(normalHandshake[:20]); !errors.Is(err, ErrorMissingData) || n != 0 {
+		t.Errorf("Expected nothing consumed with ErrorMissingData, got %d: %v", n, err)
+	}
+}
+
+func TestDecodeUnexpectedSequence(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(patchHandshake(3, 0x01)); !errors.Is(err, ErrorUnexpectedSequence) {
+		t.Errorf("Expected ErrorUnexpectedSequence for sequence 1, got: %v", err)
+	}
+}
+
+func TestDecodeTruncated(t *testing.T) {
+	decode := func(buf []byte) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+
+		sql := MySQLv10{}
+		return sql.Decode(buf)
+	}
+
+	for i := 0; i < len(normalHandshake); i++ {
+		// Truncated buffer with the original packet length
+		if err := decode(normalHandshake[:i]); !errors.Is(err, ErrorMissingData) {
+			t.Errorf("Expected ErrorMissingData truncated at %d, got: %v", i, err)
+		}
+
+		// Packet length rewritten to match, so the decoder has to notice the fields are short.
+		// Cutting into the auth plugin name still decodes since its terminator is optional.
+		if i < 4 {
+			continue
+		}
+		buf := append([]byte{}, normalHandshake[:i]...)
+		buf[0], buf[1], buf[2] = byte(i-4), 0, 0
+		if err := decode(buf); err != nil && !errors.Is(err, ErrorMissingData) {
+			t.Errorf("Expected ErrorMissingData or no error with length %d, got: %v", i-4, err)
+		}
+	}
+}
+
+func TestDecodeTrace(t *testing.T) {
+	var steps []DecodeStep
+	opts := DecodeOptions{Trace: func(step DecodeStep) { steps = append(steps, step) }}
+
+	sql := MySQLv10{}
+	if err := sql.DecodeWithOptions(normalHandshake, opts); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	expected := []struct {
+		field  string
+		offset int
+		size   int
+	}{
+		{"header", 0, 4},
+		{"protocol_version", 4, 1},
+		{"server_version", 5, 7},
+		{"connection_id", 12, 4},
+		{"auth_plugin_data_part_1", 16, 8},
+		{"filler", 24, 1},
+		{"capability_flags_1", 25, 2},
+		{"character_set", 27, 1},
+		{"status_flags", 28, 2},
+		{"capability_flags_2", 30, 2},
+		{"auth_plugin_data_len", 32, 1},
+		{"reserved", 33, 10},
+		{"auth_plugin_data_part_2", 43, 13},
+		{"auth_plugin_name", 56, len("caching_sha2_password") + 1},
+	}
+	if len(steps) != len(expected) {
+		t.Fatalf("Expected %d steps, got %d: %v", len(expected), len(steps), steps)
+	}
+	for i, e := range expected {
+		step := steps[i]
+		if step.Field != e.field || step.Offset != e.offset || len(step.Raw) != e.size || step.Err != nil {
+			t.Errorf("Expected %s at %d with %d bytes, got %s at %d with %d bytes: %v", e.field, e.offset, e.size, step.Field, step.Offset, len(step.Raw), step.Err)
+		}
+		if !bytes.Equal(step.Raw, normalHandshake[e.offset:e.offset+e.size]) {
+			t.Errorf("Raw bytes of %s don't match the packet: %x", e.field, step.Raw)
+		}
+	}
+	if steps[2].Value != "8.0.21" || steps[13].Value != "caching_sha2_password" {
+		t.Errorf("Unexpected decoded values: %v, %v", steps[2].Value, steps[13].Value)
+	}
+
+	// Cut short after the connection id, the last step reports where the auth data should have started
+	buf := append([]byte{}, normalHandshake[:18]...)
+	buf[0], buf[1], buf[2] = 14, 0, 0
+	steps = nil
+	if err := sql.DecodeWithOptions(buf, opts); !errors.Is(err, ErrorMissingData) {
+		t.Fatalf("Expected ErrorMissingData, got: %v", err)
+	}
+	last := steps[len(steps)-1]
+	if !errors.Is(last.Err, ErrorMissingData) || last.Offset != 16 || steps[len(steps)-2].Field != "connection_id" {
+		t.Errorf("Expected the failure at offset 16 after connection_id, got %+v", last)
+	}
+}
+
+func TestDecodeError(t *testing.T) {
+	// Cut short after connection_id with the length rewritten to match
+	truncated := append([]byte{}, normalHandshake[:16]...)
+	truncated[0] = 12
+
+	// Cut short in the fixed size extended fields, after character_set and status_flags
+	extended := append([]byte{}, normalHandshake[:30]...)
+	extended[0] = 26
+
+	tests := []struct {
+		name   string
+		buf    []byte
+		field  string
+		offset int
+		err    error
+	}{
+		{"after connection id", truncated, "auth_plugin_data_part_1", 16, ErrorMissingData},
+		{"in extended fields", extended, "capability_flags_2", 30, ErrorMissingData},
+		{"short header", normalHandshake[:3], "header", 0, ErrorMissingData},
+		{"short packet", normalHandshake[:40], "header", 0, ErrorMissingData},
+		{"sequence", patchHandshake(3, 1), "header", 0, ErrorUnexpectedSequence},
+		{"v9 handshake", v9Handshake, "protocol_version", 4, ErrorInvalidProtocol},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		err := sql.Decode(test.buf)
+		var decodeErr *DecodeError
+		if !errors.As(err, &decodeErr) || !errors.Is(err, test.err) {
+			t.Errorf("%s: expected a DecodeError wrapping %v, got: %v", test.name, test.err, err)
+			continue
+		}
+		if decodeErr.Field != test.field || decodeErr.Offset != test.offset {
+			t.Errorf("%s: expected %s at offset %d, got %s at %d", test.name, test.field, test.offset, decodeErr.Field, decodeErr.Offset)
+		}
+	}
+
+	// The offset and field are in the message for triaging from logs
+	err := (&MySQLv10{}).Decode(truncated)
+	if !strings.Contains(err.Error(), "auth_plugin_data_part_1 at offset 16") {
+		t.Errorf("Error didn't say where decoding failed: %s", err)
+	}
+}
+
+func TestDecodeUnterminatedServerVersion(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+	}{
+		// Packet is only the protocol version and an unterminated "8.0.21"
+		{name: "Version at the end", buf: []byte{0x07, 0x00, 0x00, 0x00, 0x0a, '8', '.', '0', '.', '2', '1'}},
+		// Every null in the packet overwritten, so the version runs into the bytes that should follow it
+		{name: "Version runs into the fields", buf: func() []byte {
+			buf := append([]byte{}, normalHandshake...)
+			for i := 5; i < len(buf); i++ {
+				if buf[i] == 0 {
+					buf[i] = 'x'
+				}
+			}
+			return buf
+		}()},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		if err := sql.Decode(test.buf); !errors.Is(err, ErrorMissingData) {
+			t.Errorf("%s: expected ErrorMissingData, got: %v", test.name, err)
+		}
+		if sql.ServerVersion != "" {
+			t.Errorf("%s: server version shouldn't be set from an unterminated string: %s", test.name, sql.ServerVersion)
+		}
+	}
+}
+
+// Run with: go test -fuzz=FuzzDecode ./mysqlscan
+func FuzzDecode(f *testing.F) {
+	f.Add(normalHandshake)
+	f.Add(patchHandshake(25, 0x01, 0xaa))
+	f.Add(v9Handshake)
+
+	// Only a panic fails, any error for garbage input is expected
+	f.Fuzz(func(t *testing.T, buf []byte) {
+		sql := MySQLv10{}
+		sql.Decode(buf)
+	})
+}
+
+func TestDecodeSuspiciousAuthLen(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.Suspicious {
+		t.Errorf("Packet capture shouldn't be suspicious")
+	}
+
+	// auth_plugin_data_len at offset 32, the capture really has 21 bytes of auth data
+	for _, authLen := range []byte{200, 3} {
+		sql := MySQLv10{}
+		if err := sql.Decode(patchHandshake(32, authLen)); err != nil {
+			t.Fatalf("Failed to decode handshake with auth_plugin_data_len %d: %s", authLen, err)
+		}
+		if !sql.Suspicious || !strings.Contains(sql.String(), "possibly a honeypot") {
+			t.Errorf("Expected auth_plugin_data_len %d to be suspicious: %s", authLen, sql.String())
+		}
+		if sql.ScrambleLength() != 20 || sql.AuthPlugin != "caching_sha2_password" {
+			t.Errorf("Expected the normal auth data layout with auth_plugin_data_len %d: %s", authLen, sql.String())
+		}
+	}
+}
+
+func TestProtocol41Warning(t *testing.T) {
+	sql := MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if !sql.Protocol41 || len(sql.Warnings()) != 0 {
+		t.Errorf("Expected Protocol41 without warnings: %s", sql.String())
+	}
+
+	// Clear CLIENT_PROTOCOL_41 (0x0200) in capability_flags_1 at offset 25
+	buf := patchHandshake(25, 0xff, 0xfd)
+	sql = MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.Protocol41 {
+		t.Errorf("Expected Protocol41 to be false")
+	}
+	if !strings.Contains(sql.String(), "CLIENT_PROTOCOL_41 not set") {
+		t.Errorf("String() didn't warn about CLIENT_PROTOCOL_41: %s", sql.String())
+	}
+}
+
+func TestDecodePre41(t *testing.T) {
+	// MySQL 4.0 layout, capability_flags_1 without CLIENT_PROTOCOL_41 then character_set and status_flags
+	handshake := []byte{
+		0x0a, 0x34, 0x2e, 0x30, 0x2e, 0x32, 0x37, 0x00, 0x05, 0x00, 0x00, 0x00, 0x41, 0x42, 0x43, 0x44,
+		0x45, 0x46, 0x47, 0x48, 0x00, 0x2c, 0xa0, 0x08, 0x02, 0x00,
+	}
+	tests := []struct {
+		name   string
+		filler []byte
+	}{
+		{name: "No filler", filler: nil},
+		{name: "Zeroed filler", filler: make([]byte, 13)},
+		// Would be capability_flags_2 with CLIENT_PLUGIN_AUTH and a plugin name in the 4.1 layout
+		{name: "Non-zero filler", filler: []byte{0x08, 0x00, 0x15, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x61, 0x62, 0x00}},
+	}
+
+	for _, test := range tests {
+		payload := append(append([]byte{}, handshake...), test.filler...)
+		buf := append([]byte{byte(len(payload)), 0x00, 0x00, 0x00}, payload...)
+
+		sql := MySQLv10{}
+		if err := sql.Decode(buf); err != nil {
+			t.Errorf("%s: failed to decode handshake: %s", test.name, err)
+			continue
+		}
+		if sql.ServerVersion != "4.0.27" || sql.Protocol41 || sql.Capabilities != 0xa02c || sql.CharacterSet != 0x08 || sql.Status != 0x0002 {
+			t.Errorf("%s: handshake didn't match expected: %s", test.name, sql.String())
+		}
+		if sql.AuthPlugin != "" || string(sql.AuthData) != "ABCDEFGH" {
+			t.Errorf("%s: expected only the 8 bytes of auth data and no plugin: %s", test.name, sql.String())
+		}
+	}
+}
+
+func TestDecodeReuse(t *testing.T) {
+	// Protocol version 11 at 4, auth_plugin_data_len of 4 at 32 and a reserved byte set at 33
+	marked := patchHandshake(4, 11)
+	marked[32], marked[33] = 0x04, 0x01
+
+	sql := MySQLv10{}
+	if err := sql.DecodeWithOptions(marked, DecodeOptions{AcceptAnyProtocolVersion: true}); err != nil {
+		t.Fatalf("Failed to decode marked handshake: %s", err)
+	}
+	if !sql.ForcedDecode || !sql.Suspicious || !sql.NonZeroReserved {
+		t.Fatalf("Expected the marked handshake to set its flags: %s", sql.String())
+	}
+
+	// A pre-4.1 handshake into the same struct has none of the extended fields to set
+	payload := []byte{
+		0x0a, 0x34, 0x2e, 0x30, 0x2e, 0x32, 0x37, 0x00, 0x05, 0x00, 0x00, 0x00, 0x41, 0x42, 0x43, 0x44,
+		0x45, 0x46, 0x47, 0x48, 0x00, 0x2c, 0xa0,
+	}
+	if err := sql.Decode(append([]byte{byte(len(payload)), 0x00, 0x00, 0x00}, payload...)); err != nil {
+		t.Fatalf("Failed to decode pre-4.1 handshake: %s", err)
+	}
+	if sql.ForcedDecode || sql.Suspicious || sql.NonZeroReserved || sql.AuthDataLen != 0 || sql.CharacterSet != 0 || sql.Status != 0 || sql.AuthPlugin != "" {
+		t.Errorf("Fields from the previous handshake were kept: %s", sql.String())
+	}
+	if sql.AuthDataPart2 != nil || string(sql.AuthData) != "ABCDEFGH" {
+		t.Errorf("Auth data part 2 from the previous handshake was kept: %s", sql.String())
+	}
+
+	// Nor is a weak scramble kept once decoding a handshake fails
+	weak := patchHandshake(16, bytes.Repeat([]byte{'A'}, 8)...)
+	copy(weak[43:55], bytes.Repeat([]byte{'A'}, 12))
+	if err := sql.Decode(weak); err != nil || !sql.WeakScramble {
+		t.Fatalf("Expected weak scramble to be decoded: %v %s", err, sql.String())
+	}
+	if err := sql.Decode(normalHandshake[:20]); err == nil || sql.WeakScramble {
+		t.Errorf("Expected a failed decode to clear the weak scramble: %v", err)
+	}
+
+	// A failed decode doesn't leave the previous packet behind either
+	if err := sql.Decode(normalHandshake[:5]); err == nil {
+		t.Fatalf("Expected truncated handshake to fail")
+	}
+	if !reflect.DeepEqual(sql, MySQLv10{}) {
+		t.Errorf("Fields from the previous handshake were kept: %s", sql.String())
+	}
+
+	// Nor does one failing part way through, after the connection id but before the auth data
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	truncated := append([]byte{20, 0x00, 0x00, 0x00}, patchHandshake(12, 0x2a)[4:24]...)
+	if err := sql.Decode(truncated); err == nil {
+		t.Fatalf("Expected truncated handshake to fail")
+	}
+	if sql.ConnectionId != 42 || sql.Capabilities != 0 || sql.AuthData != nil || sql.AuthPlugin != "" || sql.CharacterSet != 0 || !bytes.Equal(sql.RawPacket, truncated) {
+		t.Errorf("Fields from the previous handshake were kept: %s", sql.String())
+	}
+}
+
+func TestDecodeShortHandshake(t *testing.T) {
+	// Minimal 4.1 handshake ending after capability_flags_1, which has CLIENT_PROTOCOL_41 and
+	// CLIENT_SECURE_CONNECTION set even though there is no extended block with part 2 of the auth data
+	payload := []byte{
+		0x0a, '5', '.', '1', '.', '7', '3', 0x00, 0x2a, 0x00, 0x00, 0x00,
+		'A', 'B', 'C', 'D', 'E', 'F', 'G', 'H', 0x00, 0x00, 0x82,
+	}
+	buf := append([]byte{byte(len(payload)), 0x00, 0x00, 0x00}, payload...)
+
+	sql := MySQLv10{}
+	if err := sql.Decode(buf); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	if sql.ServerVersion != "5.1.73" || sql.ConnectionId != 42 || !sql.Protocol41 || sql.Capabilities != ClientProtocol41|ClientSecureConnection {
+		t.Errorf("Handshake didn't match expected: %s", sql.String())
+	}
+	if string(sql.AuthData) != "ABCDEFGH" || string(sql.AuthDataPart1) != "ABCDEFGH" || sql.AuthDataPart2 != nil {
+		t.Errorf("Expected only the 8 bytes of part 1 as the auth data: %s", sql.String())
+	}
+	if sql.ScrambleLength() != 8 || sql.AuthPlugin != "" || sql.CharacterSet != 0 || sql.Status != 0 {
+		t.Errorf("Expected nothing decoded past capability_flags_1: %s", sql.String())
+	}
+}
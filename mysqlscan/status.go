@@ -0,0 +1,99 @@
+package mysqlscan
+
+import (
+	"strings"
+)
+
+// Status flags from the handshake packet, described here:
+// https://dev.mysql.com/doc/internals/en/status-flags.html#packet-Protocol::StatusFlags
+const (
+	serverStatusInTrans            = 0x0001
+	serverStatusAutocommit         = 0x0002
+	serverMoreResultsExists        = 0x0008
+	serverStatusNoGoodIndexUsed    = 0x0010
+	serverStatusNoIndexUsed        = 0x0020
+	serverStatusCursorExists       = 0x0040
+	serverStatusLastRowSent        = 0x0080
+	serverStatusDBDropped          = 0x0100
+	serverStatusNoBackslashEscapes = 0x0200
+	serverStatusMetadataChanged    = 0x0400
+	serverQueryWasSlow             = 0x0800
+	serverPSOutParams              = 0x1000
+	serverStatusInTransReadonly    = 0x2000
+	serverSessionStateChanged      = 0x4000
+)
+
+// Names of each status flag as they appear in the status flags doc, in bit order
+var statusFlagNames = []struct {
+	flag uint16
+	name string
+}{
+	{serverStatusInTrans, "SERVER_STATUS_IN_TRANS"},
+	{serverStatusAutocommit, "SERVER_STATUS_AUTOCOMMIT"},
+	{serverMoreResultsExists, "SERVER_MORE_RESULTS_EXISTS"},
+	{serverStatusNoGoodIndexUsed, "SERVER_STATUS_NO_GOOD_INDEX_USED"},
+	{serverStatusNoIndexUsed, "SERVER_STATUS_NO_INDEX_USED"},
+	{serverStatusCursorExists, "SERVER_STATUS_CURSOR_EXISTS"},
+	{serverStatusLastRowSent, "SERVER_STATUS_LAST_ROW_SENT"},
+	{serverStatusDBDropped, "SERVER_STATUS_DB_DROPPED"},
+	{serverStatusNoBackslashEscapes, "SERVER_STATUS_NO_BACKSLASH_ESCAPES"},
+	{serverStatusMetadataChanged, "SERVER_STATUS_METADATA_CHANGED"},
+	{serverQueryWasSlow, "SERVER_QUERY_WAS_SLOW"},
+	{serverPSOutParams, "SERVER_PS_OUT_PARAMS"},
+	{serverStatusInTransReadonly, "SERVER_STATUS_IN_TRANS_READONLY"},
+	{serverSessionStateChanged, "SERVER_SESSION_STATE_CHANGED"},
+}
+
+// StatusSet is the status bit-field broken out into named booleans
+type StatusSet struct {
+	InTransaction         bool
+	Autocommit            bool
+	MoreResultsExist      bool
+	NoGoodIndexUsed       bool
+	NoIndexUsed           bool
+	CursorExists          bool
+	LastRowSent           bool
+	DBDropped             bool
+	NoBackslashEscapes    bool
+	MetadataChanged       bool
+	QueryWasSlow          bool
+	PSOutParams           bool
+	InTransactionReadOnly bool
+	SessionStateChanged   bool
+}
+
+// StatusFlags decodes the raw Status bit-field into a StatusSet
+func (s *MySQLv10) StatusFlags() StatusSet {
+	has := func(flag uint16) bool {
+		return s.Status&flag != 0
+	}
+
+	return StatusSet{
+		InTransaction:         has(serverStatusInTrans),
+		Autocommit:            has(serverStatusAutocommit),
+		MoreResultsExist:      has(serverMoreResultsExists),
+		NoGoodIndexUsed:       has(serverStatusNoGoodIndexUsed),
+		NoIndexUsed:           has(serverStatusNoIndexUsed),
+		CursorExists:          has(serverStatusCursorExists),
+		LastRowSent:           has(serverStatusLastRowSent),
+		DBDropped:             has(serverStatusDBDropped),
+		NoBackslashEscapes:    has(serverStatusNoBackslashEscapes),
+		MetadataChanged:       has(serverStatusMetadataChanged),
+		QueryWasSlow:          has(serverQueryWasSlow),
+		PSOutParams:           has(serverPSOutParams),
+		InTransactionReadOnly: has(serverStatusInTransReadonly),
+		SessionStateChanged:   has(serverSessionStateChanged),
+	}
+}
+
+// Pipe separated names of the set status flags, such as SERVER_STATUS_AUTOCOMMIT
+func (s *MySQLv10) statusNames() string {
+	var names []string
+	for _, f := range statusFlagNames {
+		if s.Status&f.flag != 0 {
+			names = append(names, f.name)
+		}
+	}
+
+	return strings.Join(names, "|")
+}
@@ -0,0 +1,70 @@
+package mysqlscan
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// TimeoutDialer gives connecting and reading the handshake separate timeouts, so a host that is slow to
+// accept can be treated differently from one that accepts straight away but is slow to send its handshake
+// The read deadline is set on the connection once it's dialed and stays in force, a later deadline such as
+// one from the context of the detection can bring it forward but never push it back
+type TimeoutDialer struct {
+	// Forward dials the connection, a nil Forward connects directly
+	Forward ContextDialer
+
+	// ConnectTimeout limits the dial, zero leaves it to the context
+	ConnectTimeout time.Duration
+
+	// ReadTimeout is how long the server has to send everything after the connection is dialed,
+	// zero sets no read deadline
+	ReadTimeout time.Duration
+}
+
+// DialContext dials address within ConnectTimeout and sets the ReadTimeout deadline on the connection
+func (d *TimeoutDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	forward := d.Forward
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+
+	dialCtx := ctx
+	if d.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		dialCtx, cancel = context.WithTimeout(ctx, d.ConnectTimeout)
+		defer cancel()
+	}
+	conn, err := forward.DialContext(dialCtx, network, address)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.ReadTimeout <= 0 {
+		return conn, nil
+	}
+	deadline := time.Now().Add(d.ReadTimeout)
+	conn.SetReadDeadline(deadline)
+	return &readTimeoutConn{Conn: conn, readDeadline: deadline}, nil
+}
+
+// Connection whose read deadline is never later than readDeadline
+type readTimeoutConn struct {
+	net.Conn
+	readDeadline time.Time
+}
+
+func (c *readTimeoutConn) SetDeadline(t time.Time) error {
+	if err := c.Conn.SetWriteDeadline(t); err != nil {
+		return err
+	}
+	return c.SetReadDeadline(t)
+}
+
+// A zero t clears the deadline, leaving just the read timeout
+func (c *readTimeoutConn) SetReadDeadline(t time.Time) error {
+	if t.IsZero() || t.After(c.readDeadline) {
+		t = c.readDeadline
+	}
+	return c.Conn.SetReadDeadline(t)
+}
@@ -0,0 +1,93 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestTimeoutDialerRead(t *testing.T) {
+	// Accepts straight away but never sends the handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(2 * time.Second)
+	}()
+
+	dialer := &TimeoutDialer{ConnectTimeout: 5 * time.Second, ReadTimeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err = DetectMySQLDialer(context.Background(), dialer, ln.Addr().String())
+	if !errors.Is(err, ErrorRead) || !errors.Is(err, os.ErrDeadlineExceeded) || errors.Is(err, ErrorConnect) {
+		t.Errorf("Expected the read timeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read timeout took %s, expected about %s", elapsed, dialer.ReadTimeout)
+	}
+}
+
+func TestTimeoutDialerReadContextDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		time.Sleep(2 * time.Second)
+	}()
+
+	// The context's later deadline mustn't replace the read timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	dialer := &TimeoutDialer{ConnectTimeout: 5 * time.Second, ReadTimeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err = DetectMySQLDialer(ctx, dialer, ln.Addr().String())
+	if !errors.Is(err, ErrorRead) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the read timeout, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Read timeout took %s, expected about %s", elapsed, dialer.ReadTimeout)
+	}
+
+	// An earlier deadline still brings it forward
+	conn, err := (&TimeoutDialer{ReadTimeout: time.Hour}).DialContext(context.Background(), "tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial: %s", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(50 * time.Millisecond))
+	start = time.Now()
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) || time.Since(start) > time.Second {
+		t.Errorf("Expected the earlier deadline to end the read, got: %v after %s", err, time.Since(start))
+	}
+}
+
+func TestTimeoutDialerConnect(t *testing.T) {
+	// Never connects, the dial waits out the connect timeout
+	forward := dialFunc(func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, &net.OpError{Op: "dial", Net: network, Err: ctx.Err()}
+	})
+	dialer := &TimeoutDialer{Forward: forward, ConnectTimeout: 20 * time.Millisecond, ReadTimeout: 5 * time.Second}
+
+	_, err := DetectMySQLDialer(context.Background(), dialer, "127.0.0.1:3306")
+	var dialErr *DialError
+	if !errors.Is(err, ErrorConnect) || !errors.As(err, &dialErr) || dialErr.Category != DialTimeout {
+		t.Errorf("Expected the connect timeout, got: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+package mysqlscan
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrorTLSNotSupported is returned with the decoded handshake when the server doesn't advertise CLIENT_SSL
+	ErrorTLSNotSupported = errors.New("Server doesn't advertise CLIENT_SSL so the connection can't be upgraded to TLS")
+
+	// ErrorTLSHandshake wraps the error from the TLS handshake after the SSL request was sent
+	ErrorTLSHandshake = errors.New("Failed to upgrade the MySQL connection to TLS")
+)
+
+// TLSInfo describes the TLS connection negotiated by DetectMySQLTLS and the certificate the server presented
+type TLSInfo struct {
+	// Version is the negotiated TLS version, such as TLS 1.3
+	Version string `json:"version"`
+
+	CipherSuite string `json:"cipher_suite"`
+
+	// Subject and Issuer of the server's leaf certificate
+	Subject string `json:"subject"`
+	Issuer  string `json:"issuer"`
+
+	// NotAfter is when the server's leaf certificate expires
+	NotAfter time.Time `json:"not_after"`
+}
+
+func (i *TLSInfo) String() string {
+	fields := []string{
+		fmt.Sprintf("Version:%s", i.Version),
+		fmt.Sprintf("CipherSuite:%s", i.CipherSuite),
+		fmt.Sprintf("Subject:%s", i.Subject),
+		fmt.Sprintf("Issuer:%s", i.Issuer),
+		fmt.Sprintf("NotAfter:%s", i.NotAfter.Format(time.DateOnly)),
+	}
+
+	return "{" + strings.Join(fields, " ") + "}"
+}
+
+// DetectMySQLTLS detects MySQL on host like DetectMySQLDialer then upgrades the connection to TLS the way a
+// client does, sending an SSL request packet in place of the handshake response, and sets TLS on the result
+// This works against servers with require_secure_transport, which still send a plaintext handshake first
+// A nil config verifies the certificate against the system roots with the host as the server name
+// If MySQL was detected but the upgrade failed the handshake is returned along with ErrorTLSNotSupported or
+// ErrorTLSHandshake. Nothing is sent after the TLS handshake so the server never sees a login attempt
+// The SSL request is described here:
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::SSLRequest
+func DetectMySQLTLS(ctx context.Context, dialer ContextDialer, host string, config *tls.Config) (*MySQLv10, error) {
+	start := time.Now()
+	conn, err := connect(ctx, dialer, host)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	sql, err := detectConn(ctx, conn, host, start, readHandshake, decodeHandshake)
+	if err != nil {
+		return nil, err
+	}
+	if !sql.SupportsTLS() {
+		return sql, ErrorTLSNotSupported
+	}
+
+	if err := writePacket(conn, 1, sslRequest(sql.Capabilities&loginCapabilities|ClientSSL)); err != nil {
+		return sql, fmt.Errorf("%w: %w", ErrorTLSHandshake, contextErr(ctx, err))
+	}
+
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName, _, _ = net.SplitHostPort(host)
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return sql, fmt.Errorf("%w: %w", ErrorTLSHandshake, contextErr(ctx, err))
+	}
+
+	sql.TLS = newTLSInfo(tlsConn.ConnectionState())
+	return sql, nil
+}
+
+func newTLSInfo(state tls.ConnectionState) *TLSInfo {
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+	}
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		info.Subject = leaf.Subject.String()
+		info.Issuer = leaf.Issuer.String()
+		info.NotAfter = leaf.NotAfter
+	}
+
+	return info
+}
+
+// SSLRequest payload, the start of a HandshakeResponse41 up to the filler
+func sslRequest(capabilities uint32) []byte {
+	buf := binary.LittleEndian.AppendUint32(nil, capabilities)
+	// max_packet_size(4) of 16MB, character_set(1) utf8mb4_general_ci then 23 bytes of filler
+	buf = binary.LittleEndian.AppendUint32(buf, 1<<24)
+	buf = append(buf, 45)
+	buf = append(buf, make([]byte, 23)...)
+
+	return buf
+}
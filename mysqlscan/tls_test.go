@@ -0,0 +1,122 @@
+package mysqlscan
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Self-signed certificate for 127.0.0.1, returned with a pool trusting it
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "mysql-test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %s", err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(leaf)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, pool
+}
+
+// Fake server that sends packet then expects an SSL request and upgrades the connection with cert
+// The capabilities of the SSL request are sent on the returned channel
+func serveTLS(t *testing.T, packet []byte, cert tls.Certificate) (string, <-chan uint32) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	requests := make(chan uint32, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write(packet)
+
+		// 4 byte header with sequence 1 then the 32 byte SSL request
+		request := make([]byte, 4+32)
+		if _, err := io.ReadFull(conn, request); err != nil || request[0] != 32 || request[3] != 1 {
+			return
+		}
+		requests <- binary.LittleEndian.Uint32(request[4:8])
+
+		tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+		tlsConn.Handshake()
+		tlsConn.Read(make([]byte, 1))
+	}()
+
+	return ln.Addr().String(), requests
+}
+
+func TestDetectMySQLTLS(t *testing.T) {
+	cert, pool := testCertificate(t)
+	host, requests := serveTLS(t, normalHandshake, cert)
+
+	sql, err := DetectMySQLTLS(context.Background(), nil, host, &tls.Config{RootCAs: pool})
+	if err != nil {
+		t.Fatalf("Failed to upgrade to TLS: %s", err)
+	}
+	if capabilities := <-requests; capabilities&ClientSSL == 0 || capabilities&ClientProtocol41 == 0 {
+		t.Errorf("SSL request didn't set CLIENT_SSL and CLIENT_PROTOCOL_41: 0x%08x", capabilities)
+	}
+
+	if sql.ServerVersion != "8.0.21" || sql.TLS == nil {
+		t.Fatalf("Expected the handshake with TLS info: %s", sql.String())
+	}
+	if sql.TLS.Version != "TLS 1.3" || sql.TLS.Subject != "CN=mysql-test" || sql.TLS.Issuer != "CN=mysql-test" {
+		t.Errorf("TLS info didn't match expected: %s", sql.TLS)
+	}
+	if !strings.Contains(sql.String(), "TLS:{Version:TLS 1.3 ") {
+		t.Errorf("String() didn't include the TLS info: %s", sql.String())
+	}
+}
+
+func TestDetectMySQLTLSFailed(t *testing.T) {
+	cert, _ := testCertificate(t)
+
+	// The self-signed certificate isn't trusted by the system roots
+	host, _ := serveTLS(t, normalHandshake, cert)
+	sql, err := DetectMySQLTLS(context.Background(), nil, host, nil)
+	if !errors.Is(err, ErrorTLSHandshake) || sql == nil || sql.TLS != nil {
+		t.Errorf("Expected ErrorTLSHandshake with the handshake, got %v: %v", sql, err)
+	}
+
+	// Clear CLIENT_SSL (0x0800) in capability_flags_1 at offset 25
+	host, _ = serveTLS(t, patchHandshake(26, normalHandshake[26]&^0x08), cert)
+	sql, err = DetectMySQLTLS(context.Background(), nil, host, nil)
+	if !errors.Is(err, ErrorTLSNotSupported) || sql == nil || sql.SupportsTLS() {
+		t.Errorf("Expected ErrorTLSNotSupported with the handshake, got %v: %v", sql, err)
+	}
+}
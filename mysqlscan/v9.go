@@ -0,0 +1,112 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// MySQLv9 is the MySQL v9 handshake packet sent by servers older than 3.21.0
+// This packet is described here:
+// https://dev.mysql.com/doc/internals/en/connection-phase-packets.html#packet-Protocol::HandshakeV9
+type MySQLv9 struct {
+	// ServerVersion in human readable version
+	ServerVersion string
+
+	// ConnectionId from the handshake packet, referred to as thread id in older docs
+	ConnectionId uint32
+
+	// Scramble is the auth data used by the old password hashing
+	Scramble []byte
+
+	// RawPacket is a copy of the exact packet decoded, header included
+	RawPacket []byte
+}
+
+// Decode the v9 handshake packet given the byte slice
+// The layout is much shorter than v10, there are no capability flags, character set or status
+func (s *MySQLv9) Decode(buf []byte) error {
+	if len(buf) < 4 {
+		return ErrorMissingData
+	}
+
+	// First 3 bytes are the packet length, then the sequence byte same as v10
+	pktLen := int(uint32(buf[0]) | uint32(buf[1])<<8 | uint32(buf[2])<<16)
+	if buf[3] != 0 {
+		return ErrorUnexpectedSequence
+	}
+	if pktLen+4 > len(buf) {
+		return ErrorMissingData
+	}
+	buf = buf[:pktLen+4]
+	s.RawPacket = append([]byte{}, buf...)
+	pos := 4
+
+	// protocol_version(1)
+	if pos >= len(buf) {
+		return ErrorMissingData
+	}
+	if 9 != buf[pos] {
+		return ErrorInvalidProtocol
+	}
+	pos += 1
+
+	// server_version(null terminated string)
+	end := bytes.IndexByte(buf[pos:], 0)
+	if end == -1 {
+		return ErrorMissingData
+	}
+	s.ServerVersion = string(buf[pos : pos+end])
+	pos += end + 1
+
+	// connection_id(4)
+	if pos+4 > len(buf) {
+		return ErrorMissingData
+	}
+	s.ConnectionId = binary.LittleEndian.Uint32(buf[pos : pos+4])
+	pos += 4
+
+	// scramble(null terminated string)
+	end = bytes.IndexByte(buf[pos:], 0)
+	if end == -1 {
+		return ErrorMissingData
+	}
+	s.Scramble = make([]byte, end)
+	copy(s.Scramble, buf[pos:pos+end])
+
+	return nil
+}
+
+// MySQLv10 representation of the v9 handshake so both versions can be reported the same way
+// Only the fields v9 has are populated, with the scramble as AuthData
+func (s *MySQLv9) MySQLv10() *MySQLv10 {
+	return &MySQLv10{
+		ProtocolVersion: 9,
+		ServerVersion:   s.ServerVersion,
+		ConnectionId:    s.ConnectionId,
+		AuthData:        s.Scramble,
+		RawPacket:       s.RawPacket,
+	}
+}
+
+// Decode whichever handshake version buf holds, v9 handshakes are converted to MySQLv10
+func decodeHandshake(buf []byte) (*MySQLv10, error) {
+	return decodeHandshakeOptions(buf, DecodeOptions{})
+}
+
+// Same as decodeHandshake with the checks relaxed by opts, a v9 handshake is still decoded as v9
+func decodeHandshakeOptions(buf []byte, opts DecodeOptions) (*MySQLv10, error) {
+	if len(buf) > 4 && buf[4] == 9 {
+		v9 := MySQLv9{}
+		if err := v9.Decode(buf); err != nil {
+			return nil, err
+		}
+		return v9.MySQLv10(), nil
+	}
+
+	sql := MySQLv10{}
+	if err := sql.DecodeWithOptions(buf, opts); err != nil {
+		return nil, err
+	}
+
+	return &sql, nil
+}
@@ -0,0 +1,47 @@
+package mysqlscan
+
+import (
+	"bytes"
+	"testing"
+)
+
+// Synthesized v9 handshake for a 3.20.32 server with connection id 7
+var v9Handshake = []byte{
+	0x16, 0x00, 0x00, 0x00, 0x09, 0x33, 0x2e, 0x32, 0x30, 0x2e, 0x33, 0x32, 0x00, 0x07, 0x00, 0x00,
+	0x00, 0x61, 0x62, 0x63, 0x64, 0x65, 0x66, 0x67, 0x68, 0x00,
+}
+
+func TestDecodeV9(t *testing.T) {
+	sql := MySQLv9{}
+	if err := sql.Decode(v9Handshake); err != nil {
+		t.Fatalf("Failed to decode v9 handshake: %s", err)
+	}
+	if sql.ServerVersion != "3.20.32" || sql.ConnectionId != 7 || !bytes.Equal(sql.Scramble, []byte("abcdefgh")) {
+		t.Errorf("Decoded v9 handshake didn't match expected: %+v", sql)
+	}
+
+	// Truncating anywhere should be missing data rather than a panic
+	for i := 0; i < len(v9Handshake); i++ {
+		if err := (&MySQLv9{}).Decode(v9Handshake[:i]); err != ErrorMissingData {
+			t.Errorf("Expected ErrorMissingData truncated at %d, got: %v", i, err)
+		}
+	}
+}
+
+func TestDecodeHandshakeRouting(t *testing.T) {
+	sql, err := decodeHandshake(v9Handshake)
+	if err != nil {
+		t.Fatalf("Failed to decode v9 handshake: %s", err)
+	}
+	if sql.ProtocolVersion != 9 || sql.ServerVersion != "3.20.32" || !bytes.Equal(sql.AuthData, []byte("abcdefgh")) {
+		t.Errorf("v9 handshake wasn't converted to MySQLv10: %+v", sql)
+	}
+
+	sql, err = decodeHandshake(normalHandshake)
+	if err != nil {
+		t.Fatalf("Failed to decode v10 handshake: %s", err)
+	}
+	if sql.ProtocolVersion != 10 || sql.ServerVersion != "8.0.21" {
+		t.Errorf("v10 handshake wasn't decoded: %+v", sql)
+	}
+}
@@ -0,0 +1,118 @@
+package mysqlscan
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	FlavorMySQL   = "MySQL"
+	FlavorMariaDB = "MariaDB"
+	FlavorPercona = "Percona"
+	FlavorUnknown = "unknown"
+)
+
+// MariaDB prefixes its real version with this so old replication clients don't choke on a 10.x major version
+const mariaDBVersionPrefix = "5.5.5-"
+
+var ErrorInvalidVersion = errors.New("Server version isn't in major.minor.patch form")
+
+// ServerVersionInfo is ServerVersion broken out into its numeric parts so versions can be compared
+type ServerVersionInfo struct {
+	Major int
+	Minor int
+	Patch int
+
+	// Suffix is anything after the numeric version without the leading dash, e.g. log or MariaDB-1:10.6.12
+	Suffix string
+}
+
+// String of just the numeric version, e.g. 8.0.21
+func (v ServerVersionInfo) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Version parses ServerVersion, see ParseServerVersion
+func (s *MySQLv10) Version() (ServerVersionInfo, error) {
+	return ParseServerVersion(s.ServerVersion)
+}
+
+// ParseServerVersion from a handshake into its numeric parts and suffix
+// The MariaDB 5.5.5- replication prefix is skipped so the real MariaDB version is returned
+func ParseServerVersion(version string) (ServerVersionInfo, error) {
+	info := ServerVersionInfo{}
+
+	if strings.HasPrefix(version, mariaDBVersionPrefix) {
+		if rest := version[len(mariaDBVersionPrefix):]; rest != "" && rest[0] >= '0' && rest[0] <= '9' {
+			version = rest
+		}
+	}
+
+	// Numeric part ends at the first dash, anything after it is the vendor suffix
+	numeric := version
+	if pos := strings.IndexByte(version, '-'); pos != -1 {
+		numeric = version[:pos]
+		info.Suffix = version[pos+1:]
+	}
+
+	parts := strings.Split(numeric, ".")
+	if len(parts) != 3 {
+		return ServerVersionInfo{}, ErrorInvalidVersion
+	}
+
+	fields := []*int{&info.Major, &info.Minor, &info.Patch}
+	for i, part := range parts {
+		if !isDigits(part) {
+			return ServerVersionInfo{}, ErrorInvalidVersion
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return ServerVersionInfo{}, ErrorInvalidVersion
+		}
+		*fields[i] = n
+	}
+
+	return info, nil
+}
+
+// Flavor of server guessed from ServerVersion, one of MySQL, MariaDB, Percona or unknown
+//
+// MariaDB always includes MariaDB in the version, e.g. 5.5.5-10.6.12-MariaDB-1:10.6.12+maria~ubu2004
+// Percona Server doesn't say so in the handshake but follows the MySQL version with a numeric
+// build number, e.g. 8.0.26-16 or 5.7.35-38-log, where vanilla MySQL only has vendor suffixes like -log
+func (s *MySQLv10) Flavor() string {
+	version := s.ServerVersion
+	lower := strings.ToLower(version)
+
+	switch {
+	case strings.Contains(lower, "mariadb"):
+		return FlavorMariaDB
+	case strings.Contains(lower, "percona"):
+		return FlavorPercona
+	case version == "" || version[0] < '0' || version[0] > '9':
+		return FlavorUnknown
+	}
+
+	if parts := strings.Split(version, "-"); len(parts) > 1 && isDigits(parts[1]) {
+		return FlavorPercona
+	}
+
+	return FlavorMySQL
+}
+
+// Whether s is made up of only ASCII digits, false for an empty string
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,64 @@
+package mysqlscan
+
+import (
+	"testing"
+)
+
+func TestFlavor(t *testing.T) {
+	tests := []struct {
+		version string
+		flavor  string
+	}{
+		{version: "5.5.5-10.6.12-MariaDB-1:10.6.12+maria~ubu2004", flavor: FlavorMariaDB},
+		{version: "10.11.2-MariaDB", flavor: FlavorMariaDB},
+		{version: "8.0.21", flavor: FlavorMySQL},
+		{version: "5.7.30-log", flavor: FlavorMySQL},
+		{version: "8.0.32-0ubuntu0.20.04.2", flavor: FlavorMySQL},
+		{version: "8.0.26-16", flavor: FlavorPercona},
+		{version: "5.7.35-38-log", flavor: FlavorPercona},
+		{version: "5.6.51-91.0-Percona", flavor: FlavorPercona},
+		{version: "SSH-2.0-OpenSSH_8.9", flavor: FlavorUnknown},
+		{version: "", flavor: FlavorUnknown},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{ServerVersion: test.version}
+		if flavor := sql.Flavor(); flavor != test.flavor {
+			t.Errorf("Flavor of '%s' didn't match expected '%s': %s", test.version, test.flavor, flavor)
+		}
+	}
+}
+
+func TestParseServerVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		info    ServerVersionInfo
+		err     bool
+	}{
+		{version: "8.0.21", info: ServerVersionInfo{Major: 8, Minor: 0, Patch: 21}},
+		{version: "5.7.30-log", info: ServerVersionInfo{Major: 5, Minor: 7, Patch: 30, Suffix: "log"}},
+		{version: "8.0.32-0ubuntu0.20.04.2", info: ServerVersionInfo{Major: 8, Minor: 0, Patch: 32, Suffix: "0ubuntu0.20.04.2"}},
+		{
+			version: "5.5.5-10.6.12-MariaDB-1:10.6.12+maria~ubu2004",
+			info:    ServerVersionInfo{Major: 10, Minor: 6, Patch: 12, Suffix: "MariaDB-1:10.6.12+maria~ubu2004"},
+		},
+		{version: "10.11.2-MariaDB", info: ServerVersionInfo{Major: 10, Minor: 11, Patch: 2, Suffix: "MariaDB"}},
+		{version: "5.5.5-log", info: ServerVersionInfo{Major: 5, Minor: 5, Patch: 5, Suffix: "log"}},
+		{version: "", err: true},
+		{version: "8.0", err: true},
+		{version: "8.0.21.1", err: true},
+		{version: "8.x.21", err: true},
+		{version: "8..21", err: true},
+		{version: "SSH-2.0-OpenSSH_8.9", err: true},
+	}
+
+	for _, test := range tests {
+		info, err := ParseServerVersion(test.version)
+		if (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s': %v", test.version, err)
+		}
+		if info != test.info {
+			t.Errorf("Parsed version of '%s' didn't match expected\ngot:  %+v\nwant: %+v", test.version, info, test.info)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package mysqlscan
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// X Protocol framing, each message is a 4 byte little endian length then the message type and a protobuf payload
+// https://dev.mysql.com/doc/dev/mysql-server/latest/page_mysqlx_protocol_messages.html
+const (
+	xMessageNotice = 0x0b // Mysqlx.Notice.Frame, sent by the server on connect
+
+	// Largest frame expected on connect, the notice is only a few bytes so anything bigger isn't X Protocol
+	maxXFrameSize = 1 << 10
+)
+
+// ErrorXProtocol is returned when the peer is speaking the MySQL 8 X Protocol, normally on port 33060, instead of
+// the classic protocol. It's still ErrorNotMySQL since there is no handshake to decode
+var ErrorXProtocol = fmt.Errorf("%w, MySQL X Protocol detected", ErrorNotMySQL)
+
+// Check whether buf starts with the X Protocol notice frame a server sends on connect
+// Only the frame header is needed, the 5 bytes read before giving up on a classic handshake are enough
+func isXProtocol(buf []byte) bool {
+	if len(buf) < 5 || buf[4] != xMessageNotice {
+		return false
+	}
+
+	// The length counts the message type so it's never zero
+	frameLen := binary.LittleEndian.Uint32(buf[:4])
+	if frameLen < 1 || frameLen > maxXFrameSize {
+		return false
+	}
+
+	// Mysqlx.Notice.Frame starts with its type field, field 1 as a varint
+	return len(buf) == 5 || buf[5] == 0x08
+}
@@ -0,0 +1,62 @@
+package mysqlscan
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+// Captured from MySQL 8.0 on port 33060, a Mysqlx.Notice.Frame with type 5 (SERVER_HELLO)
+var xProtocolGreeting = []byte{0x05, 0x00, 0x00, 0x00, 0x0b, 0x08, 0x05, 0x1a, 0x00}
+
+func TestDecodeXProtocol(t *testing.T) {
+	tests := []struct {
+		name string
+		buf  []byte
+		err  error
+	}{
+		{name: "Greeting", buf: xProtocolGreeting, err: ErrorXProtocol},
+		{name: "Greeting prefix", buf: xProtocolGreeting[:5], err: ErrorXProtocol},
+		{name: "Oversized frame", buf: []byte{0x05, 0x00, 0x01, 0x00, 0x0b, 0x08}, err: ErrorNotMySQL},
+		{name: "Not a notice", buf: []byte{0x05, 0x00, 0x00, 0x00, 0x0b, 0x10}, err: ErrorNotMySQL},
+	}
+
+	for _, test := range tests {
+		sql := MySQLv10{}
+		err := sql.Decode(test.buf)
+		if !errors.Is(err, test.err) || !errors.Is(err, ErrorNotMySQL) {
+			t.Errorf("%s: error didn't match expected '%s': %v", test.name, test.err, err)
+		}
+		if test.err != ErrorXProtocol && errors.Is(err, ErrorXProtocol) {
+			t.Errorf("%s: expected the frame not to be identified as X Protocol", test.name)
+		}
+
+		if err := sql.DecodeBanner(test.buf); !errors.Is(err, test.err) {
+			t.Errorf("%s: banner error didn't match expected '%s': %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestDetectMySQLXProtocol(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(xProtocolGreeting)
+		conn.Close()
+	}()
+
+	_, err = DetectMySQLDialer(context.Background(), nil, ln.Addr().String())
+	if !errors.Is(err, ErrorXProtocol) || !strings.Contains(err.Error(), "MySQL X Protocol detected") {
+		t.Errorf("Expected the X Protocol to be identified, got: %v", err)
+	}
+}
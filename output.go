@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Open where results are written, path is created or truncated, an empty path is stdout
+// With compress, or a path ending in .gz, the results are gzipped
+// Closing flushes the results to the file, stdout is left open
+func createOutput(path string, compress bool) (io.WriteCloser, error) {
+	var f io.WriteCloser = nopWriteCloser{os.Stdout}
+	if path != "" {
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		f = file
+	}
+
+	if !compress && !strings.HasSuffix(path, ".gz") {
+		if path == "" {
+			return f, nil
+		}
+		return &outputFile{Writer: bufio.NewWriter(f), f: f}, nil
+	}
+
+	gz := gzip.NewWriter(f)
+	return &outputFile{Writer: bufio.NewWriter(gz), gz: gz, f: f}, nil
+}
+
+// Results file for -o, buffered since bulk scans write a record at a time
+type outputFile struct {
+	*bufio.Writer
+	// gz is nil unless the results are compressed, closing it writes the gzip footer
+	gz *gzip.Writer
+	f  io.Closer
+}
+
+func (o *outputFile) Close() error {
+	err := o.Flush()
+	if err == nil && o.gz != nil {
+		err = o.gz.Close()
+	}
+	if err != nil {
+		o.f.Close()
+		return err
+	}
+
+	return o.f.Close()
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
+
+// Writes the results of a bulk scan in one output format
+// Created once per scan so formats with a header or buffering can write them around the results
+type resultWriter interface {
+	Write(result *mysqlscan.ScanResult) error
+
+	// Flush writes out anything still buffered once every result has been written
+	Flush() error
+}
+
+// Create the result writer for format, writing any header straight away
+func newResultWriter(w io.Writer, format string) (resultWriter, error) {
+	switch format {
+	case formatCSV:
+		return newCSVResultWriter(w)
+	case formatJSONArray:
+		return newJSONArrayResultWriter(w)
+	}
+
+	return &lineResultWriter{w: w, format: format}, nil
+}
+
+// Writes each result on its own line, as text, a JSON record or just the host, or as its own YAML document
+type lineResultWriter struct {
+	w      io.Writer
+	format string
+}
+
+func (l *lineResultWriter) Write(result *mysqlscan.ScanResult) error {
+	switch l.format {
+	case formatJSON, formatJSONL:
+		return json.NewEncoder(l.w).Encode(result)
+	case formatYAML:
+		if _, err := io.WriteString(l.w, "---\n"); err != nil {
+			return err
+		}
+		return writeYAML(l.w, result)
+	case formatHosts:
+		_, err := fmt.Fprintln(l.w, result.Host)
+		return err
+	}
+
+	_, err := fmt.Fprintf(l.w, "%s: %s\n", result.Host, result.MySQL.String())
+	return err
+}
+
+func (l *lineResultWriter) Flush() error {
+	return nil
+}
+
+// Columns of the CSV output, one row per scanned host
+var csvHeader = []string{"host", "reachable", "mysql", "flavor", "version", "tls", "auth_plugin"}
+
+// Writes a header row then a row per result, hosts MySQL wasn't detected on leave the handshake columns empty
+type csvResultWriter struct {
+	w *csv.Writer
+}
+
+func newCSVResultWriter(w io.Writer) (*csvResultWriter, error) {
+	c := &csvResultWriter{w: csv.NewWriter(w)}
+	if err := c.w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (c *csvResultWriter) Write(result *mysqlscan.ScanResult) error {
+	row := []string{result.Host, strconv.FormatBool(result.Reachable()), strconv.FormatBool(result.Err == nil), "", "", "", ""}
+	if sql := result.MySQL; sql != nil {
+		row[3] = sql.Flavor()
+		row[4] = sql.ServerVersion
+		row[6] = sql.AuthPlugin
+		if !sql.BannerOnly {
+			row[5] = strconv.FormatBool(sql.SupportsTLS())
+		}
+	}
+
+	return c.w.Write(row)
+}
+
+func (c *csvResultWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// Writes the results as the elements of a single JSON array, each one as soon as it arrives rather than
+// collecting them so memory stays flat however many hosts are scanned. One element per line after the
+// opening bracket, the closing bracket is written by Flush
+type jsonArrayResultWriter struct {
+	w       io.Writer
+	enc     *json.Encoder
+	written bool
+}
+
+func newJSONArrayResultWriter(w io.Writer) (*jsonArrayResultWriter, error) {
+	if _, err := io.WriteString(w, "[\n"); err != nil {
+		return nil, err
+	}
+
+	return &jsonArrayResultWriter{w: w, enc: json.NewEncoder(w)}, nil
+}
+
+func (j *jsonArrayResultWriter) Write(result *mysqlscan.ScanResult) error {
+	if j.written {
+		if _, err := io.WriteString(j.w, ","); err != nil {
+			return err
+		}
+	}
+	j.written = true
+
+	return j.enc.Encode(result)
+}
+
+func (j *jsonArrayResultWriter) Flush() error {
+	_, err := io.WriteString(j.w, "]\n")
+	return err
+}
+
+// Compile a -template, executed with a *mysqlscan.ScanResult for each detected host
+// Each result goes on its own line, so a newline is added unless the template already ends with one
+func parseTemplate(text string) (*template.Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	return template.New("template").Option("missingkey=error").Parse(text)
+}
+
+// Writes each result with the -template, only used for detections like the text format
+type templateResultWriter struct {
+	w    io.Writer
+	tmpl *template.Template
+}
+
+func (t *templateResultWriter) Write(result *mysqlscan.ScanResult) error {
+	return t.tmpl.Execute(t.w, result)
+}
+
+func (t *templateResultWriter) Flush() error {
+	return nil
+}
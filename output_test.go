@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+func TestResultWriters(t *testing.T) {
+	sql := mysqlscan.MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	results := []mysqlscan.ScanResult{
+		{Host: "10.0.0.1:3306", MySQL: &sql, Duration: 2 * time.Millisecond},
+		{Host: "10.0.0.2:3306", Err: fmt.Errorf("%w: %w", mysqlscan.ErrorConnect, &mysqlscan.DialError{Category: mysqlscan.DialRefused}), Duration: time.Millisecond},
+		{Host: "10.0.0.3:3306", Err: mysqlscan.ErrorNotMySQL, Duration: time.Millisecond},
+	}
+
+	tests := []struct {
+		format   string
+		expected []string
+	}{
+		{format: formatText, expected: []string{"10.0.0.1:3306: " + sql.String() + "\n"}},
+		{format: formatJSON, expected: []string{`"host":"10.0.0.1:3306","success":true,"duration":"2ms","mysql":{`}},
+		{format: formatJSONL, expected: []string{
+			`"host":"10.0.0.1:3306","success":true,"duration":"2ms","mysql":{`,
+			`{"host":"10.0.0.2:3306","success":false,"error":"` + results[1].Err.Error() + `","dial_error":"refused","duration":"1ms"}`,
+			`{"host":"10.0.0.3:3306","success":false,"error":"` + mysqlscan.ErrorNotMySQL.Error() + `","duration":"1ms"}`,
+		}},
+		{format: formatJSONArray, expected: []string{
+			"[\n{\"host\":\"10.0.0.1:3306\"",
+			`,{"host":"10.0.0.3:3306","success":false,"error":"` + mysqlscan.ErrorNotMySQL.Error() + `","duration":"1ms"}` + "\n]\n",
+		}},
+		{format: formatCSV, expected: []string{
+			strings.Join(csvHeader, ",") + "\n",
+			"10.0.0.1:3306,true,true,MySQL,8.0.21,true,caching_sha2_password\n",
+			"10.0.0.2:3306,false,false,,,,\n",
+			"10.0.0.3:3306,true,false,,,,\n",
+		}},
+	}
+
+	for _, test := range tests {
+		var out bytes.Buffer
+		w, err := newResultWriter(&out, test.format)
+		if err != nil {
+			t.Fatalf("%s: failed to create result writer: %s", test.format, err)
+		}
+		// Text and JSON only get detections, scanAll writes the failures to stderr for them
+		for i := range results {
+			if results[i].Err != nil && !recordsFailures(test.format) {
+				continue
+			}
+			if err := w.Write(&results[i]); err != nil {
+				t.Fatalf("%s: failed to write result: %s", test.format, err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			t.Fatalf("%s: failed to flush results: %s", test.format, err)
+		}
+
+		for _, expected := range test.expected {
+			if !strings.Contains(out.String(), expected) {
+				t.Errorf("%s: output missing '%s':\n%s", test.format, expected, out.String())
+			}
+		}
+		if test.format == formatJSONArray {
+			var records []map[string]any
+			if err := json.Unmarshal(out.Bytes(), &records); err != nil || len(records) != len(results) {
+				t.Errorf("Expected a JSON array of %d records, got %d: %v", len(results), len(records), err)
+			}
+		}
+		if test.format == formatJSONL {
+			for _, line := range strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n") {
+				if !json.Valid([]byte(line)) {
+					t.Errorf("Invalid JSON record: %s", line)
+				}
+			}
+		}
+	}
+}
+
+func TestTemplateResultWriter(t *testing.T) {
+	sql := mysqlscan.MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	tmpl, err := parseTemplate("{{.Host}} {{.MySQL.ServerVersion}} {{.MySQL.Flavor}}")
+	if err != nil {
+		t.Fatalf("Failed to parse template: %s", err)
+	}
+	var out bytes.Buffer
+	w := &templateResultWriter{w: &out, tmpl: tmpl}
+	for _, host := range []string{"10.0.0.1:3306", "10.0.0.2:3306"} {
+		if err := w.Write(&mysqlscan.ScanResult{Host: host, MySQL: &sql}); err != nil {
+			t.Fatalf("Failed to write result: %s", err)
+		}
+	}
+	if expected := "10.0.0.1:3306 8.0.21 MySQL\n10.0.0.2:3306 8.0.21 MySQL\n"; out.String() != expected {
+		t.Errorf("Template output didn't match expected:\n%s", out.String())
+	}
+
+	if _, err := parseTemplate("{{.Host"); err == nil {
+		t.Errorf("Expected an error for an unclosed action")
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// How often progress is reported during a bulk scan
+const progressInterval = 2 * time.Second
+
+// Counts of a bulk scan's hosts, updated by the workers as each host finishes
+type progress struct {
+	// total number of targets, zero when it isn't known such as when reading targets from stdin
+	total int
+
+	scanned  atomic.Int64
+	detected atomic.Int64
+}
+
+func newProgress(total int) *progress {
+	return &progress{total: total}
+}
+
+// Count a scanned host, a nil progress counts nothing
+func (p *progress) add(detected bool) {
+	if p == nil {
+		return
+	}
+	p.scanned.Add(1)
+	if detected {
+		p.detected.Add(1)
+	}
+}
+
+func (p *progress) String() string {
+	if p.total == 0 {
+		return fmt.Sprintf("scanned %d (%d detected)", p.scanned.Load(), p.detected.Load())
+	}
+
+	return fmt.Sprintf("scanned %d/%d (%d detected)", p.scanned.Load(), p.total, p.detected.Load())
+}
+
+// Write the progress to w on every tick until done is closed, then once more for the final count
+// Each report is a whole line so it can share stderr with the errors of individual hosts
+func (p *progress) report(w io.Writer, ticks <-chan time.Time, done <-chan struct{}) {
+	for {
+		select {
+		case <-ticks:
+			fmt.Fprintln(w, p)
+		case <-done:
+			fmt.Fprintln(w, p)
+			return
+		}
+	}
+}
+
+// Report the progress to w every interval, the returned function stops reporting once the final count is written
+func (p *progress) start(w io.Writer, interval time.Duration) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		p.report(w, ticker.C, done)
+		close(finished)
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(done)
+		<-finished
+	}
+}
+
+// Check whether f is a terminal, so progress is only shown to someone watching
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestProgress(t *testing.T) {
+	p := newProgress(65536)
+	p.add(true)
+	p.add(false)
+	p.add(false)
+	if expected := "scanned 3/65536 (1 detected)"; p.String() != expected {
+		t.Errorf("Progress didn't match expected '%s': %s", expected, p)
+	}
+
+	// Reading from stdin there is no total
+	if expected := "scanned 0 (0 detected)"; newProgress(0).String() != expected {
+		t.Errorf("Progress didn't match expected '%s': %s", expected, newProgress(0))
+	}
+
+	// A nil progress ignores hosts, so workers don't need to check if it's enabled
+	var none *progress
+	none.add(true)
+}
+
+func TestProgressReport(t *testing.T) {
+	p := newProgress(10)
+	ticks := make(chan time.Time)
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	var out bytes.Buffer
+	go func() {
+		p.report(&out, ticks, done)
+		close(finished)
+	}()
+
+	p.add(true)
+	ticks <- time.Now()
+	p.add(false)
+	close(done)
+	<-finished
+
+	if expected := "scanned 1/10 (1 detected)\nscanned 2/10 (1 detected)\n"; out.String() != expected {
+		t.Errorf("Progress report didn't match expected\ngot:  %q\nwant: %q", out.String(), expected)
+	}
+}
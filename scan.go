@@ -1,34 +1,756 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"text/template"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Exit codes, so scripts wrapping the tool can tell why a scan failed
+// When scanning multiple hosts the code covers the whole scan, 0 if any host is running MySQL
+const (
+	// exitDetected means MySQL was detected
+	exitDetected = 0
+
+	// exitNotMySQL means the host accepted a connection but isn't running MySQL, or no host scanned was
+	exitNotMySQL = 1
+
+	// exitConnectFailed means the host couldn't be connected to, or no host scanned could be
+	exitConnectFailed = 2
+
+	// exitUsage means the flags were invalid and nothing was scanned, or the targets couldn't be read
+	exitUsage = 3
+
+	// exitCheckFailed means MySQL was detected but failed -check-tls, -fail-on-eol or -auth, or the servers
+	// given to -compare differ
+	exitCheckFailed = 4
+
+	// exitOutputFailed means the results couldn't be written to the output, only for writing or closing it
+	exitOutputFailed = 5
+)
+
+const (
+	formatText  = "text"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+	formatYAML  = "yaml"
+	formatHosts = "hosts"
+
+	// formatJSONArray is jsonl as a single JSON array, streamed as each host finishes
+	formatJSONArray = "json-array"
 )
 
 var (
-	scanHost    string
-	scanTimeout int
+	scanHost         string
+	scanTimeout      = timeoutFlag(time.Second)
+	scanConnTimeout  timeoutFlag
+	scanReadTimeout  timeoutFlag
+	scanFormat       string
+	scanPort         int
+	scanPorts        string
+	scanHostFile     string
+	scanConcurrency  int
+	scanCheckTLS     bool
+	scanRetries      int
+	scanProxy        string
+	scanVersion      bool
+	scanBanner       bool
+	scanFailOnEOL    bool
+	scanOutput       string
+	scanGzip         bool
+	scanAuth         string
+	scanIPOnly       bool
+	scanCount        int
+	scanRate         float64
+	scanCompareHosts string
+	scanNoDNSCache   bool
+	scanForceDecode  bool
+	scanDebugDecode  bool
+	scanDumpAuth     bool
+	scanProgress     bool
+	scanTLS          bool
+	scanSkipVerify   bool
+	scanOnlyMySQL    bool
+	scanSocket       string
+	scanTemplateText string
+	scanMaxBytes     int
+	scanHTTPProxy    string
+	scanSourceIP     string
+	scanDeadline     time.Time
+	scanRunTimeout   time.Duration
+	scanSRV          string
+	scanJitter       time.Duration
+	scanRandomize    bool
+	scanSeed         uint64
+	scanVerbose      bool
+	scanVeryVerbose  bool
+
+	// scanTargets is only populated when scanning multiple hosts, such as a CIDR range or host file
+	scanTargets []string
+
+	// scanStdin reads the targets from stdin as they arrive instead of from scanTargets, for -hostfile -
+	scanStdin bool
+
+	// scanCompare is the pair of hosts from -compare, nil when not comparing
+	scanCompare []string
+
+	// scanTemplate is the compiled -template, nil when the -format is used instead
+	scanTemplate *template.Template
+
+	// scanErrors is where errors for individual hosts go when scanning multiple hosts
+	scanErrors io.Writer = io.Discard
 )
 
+// Flag value for the timeout, accepts a duration like 250ms or a bare integer number of seconds
+// Bare integers keep -t 3 working from back when the flag was only whole seconds
+type timeoutFlag time.Duration
+
+func (t *timeoutFlag) String() string {
+	return time.Duration(*t).String()
+}
+
+func (t *timeoutFlag) Set(value string) error {
+	if seconds, err := strconv.Atoi(value); err == nil {
+		*t = timeoutFlag(time.Duration(seconds) * time.Second)
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*t = timeoutFlag(d)
+	return nil
+}
+
+// Formats that write a record for every scanned host, failures included
+func recordsFailures(format string) bool {
+	return format == formatJSONL || format == formatJSONArray || format == formatCSV
+}
+
 func parseCommandLine() {
 	flag.Usage = func() {
 		fmt.Fprintf(flag.CommandLine.Output(), "Tool for checking a given host and port for running MySQL\nUsage of %s:\n", os.Args[0])
 		flag.PrintDefaults()
 	}
 
-	flag.StringVar(&scanHost, "host", "127.0.0.1:3306", "Host and port to test for running MySQL server")
-	flag.IntVar(&scanTimeout, "t", 1, "Dial timeout in seconds")
-	flag.Parse()
+	flag.StringVar(&scanHost, "host", "127.0.0.1:3306", "Host and port to test for running MySQL server, or a CIDR range such as 10.0.0.0/24")
+	flag.Var(&scanTimeout, "t", "Timeout per host as a duration such as 250ms or 2s, a bare integer is seconds")
+	flag.Var(&scanConnTimeout, "connect-timeout", "Timeout for connecting to each host, defaults to -t")
+	flag.Var(&scanReadTimeout, "read-timeout", "Timeout for the handshake once connected to each host, defaults to -t")
+	flag.StringVar(&scanFormat, "format", formatText, "Output format, either text, json, jsonl (one JSON record per scanned host), json-array (those records as one JSON array), csv, yaml or hosts (only the host:port of each detected server)")
+	flag.StringVar(&scanTemplateText, "template", "", "Go text/template to write each detected host with instead of -format, such as '{{.Host}} {{.MySQL.ServerVersion}}'")
+	flag.IntVar(&scanPort, "port", 3306, "Port to scan on each address when -host is a CIDR range")
+	flag.StringVar(&scanOutput, "o", "", "File to write results to in the -format, created or truncated, instead of stdout")
+	flag.BoolVar(&scanGzip, "gzip", false, "Compress the results with gzip, the default when -o ends in .gz")
+	flag.StringVar(&scanSocket, "socket", "", "UNIX socket of a local MySQL server to scan instead of -host, such as /var/run/mysqld/mysqld.sock")
+	flag.StringVar(&scanSRV, "srv", "", "Domain to look up _mysql._tcp SRV records for and scan each target, in priority and weight order")
+	flag.StringVar(&scanPorts, "ports", "", "Ports to scan on -host, such as 3306,3307,33060 or 3306-3310, replaces -port for a CIDR range")
+	flag.StringVar(&scanHostFile, "hostfile", "", "File of host:port targets to scan, one per line, or - to read them from stdin")
+	flag.IntVar(&scanConcurrency, "concurrency", 10, "Number of hosts to scan at once when scanning multiple hosts")
+	flag.IntVar(&scanCount, "count", 0, "Stop scanning multiple hosts once this many MySQL servers are detected, 0 scans every host")
+	flag.Float64Var(&scanRate, "rate", 0, "Most connections per second across every worker, 0 is unlimited")
+	flag.DurationVar(&scanJitter, "jitter", 0, "Random delay of up to this duration added before each connection, such as 200ms")
+	flag.BoolVar(&scanRandomize, "randomize", false, "Scan the targets from a CIDR range or host file in a random order rather than one after another")
+	flag.Uint64Var(&scanSeed, "seed", 0, "Seed for -randomize so the order can be repeated, 0 picks one at random and logs it with -v")
+	flag.IntVar(&scanMaxBytes, "maxbytes", 0, "Most bytes to read from each host, whatever arrived is decoded, 0 reads a whole handshake or banner")
+	flag.Func("deadline", "Time in RFC 3339 form, such as 2024-01-01T12:00:00Z, to abort the run at however many hosts remain", func(value string) error {
+		t, err := time.Parse(time.RFC3339, value)
+		scanDeadline = t
+		return err
+	})
+	flag.DurationVar(&scanRunTimeout, "run-timeout", 0, "Most time the whole run can take, such as 10m, separate from the per host -t, the hosts left are reported as unscanned")
+	flag.IntVar(&scanRetries, "retries", 0, "Number of times to retry a host after a connect or read error, with exponential backoff, a reset during the handshake is retried straight away")
+	flag.StringVar(&scanProxy, "proxy", "", "SOCKS5 proxy to connect through, e.g. socks5://127.0.0.1:1080")
+	flag.StringVar(&scanHTTPProxy, "http-proxy", "", "HTTP proxy as host:port to tunnel to each host through with CONNECT, instead of -proxy")
+	flag.StringVar(&scanSourceIP, "source-ip", "", "Local IP address to connect from, for choosing the interface on a host with more than one network")
+	flag.StringVar(&scanCompareHosts, "compare", "", "Two hosts as hostA,hostB to diff the handshakes of, exiting non-zero if they differ")
+	flag.StringVar(&scanAuth, "auth", "", "Credentials as user:pass to try logging in with mysql_native_password after detecting a single -host")
+	flag.BoolVar(&scanCheckTLS, "check-tls", false, "Exit with a non-zero code if a detected server doesn't advertise SSL")
+	flag.BoolVar(&scanFailOnEOL, "fail-on-eol", false, "Exit with a non-zero code if a detected server is running an end of life release series")
+	flag.BoolVar(&scanNoDNSCache, "no-dns-cache", false, "Resolve hostnames on every connection instead of once per scan")
+	flag.BoolVar(&scanIPOnly, "ip-only", false, "Refuse hosts that aren't IP addresses so no DNS lookups are made")
+	flag.BoolVar(&scanTLS, "tls", false, "Upgrade each connection to TLS after the handshake and report the TLS version and certificate")
+	flag.BoolVar(&scanSkipVerify, "tls-skip-verify", false, "Don't verify the certificate during the -tls upgrade, to report self-signed servers")
+	flag.BoolVar(&scanForceDecode, "force-decode", false, "Decode a handshake with any protocol version as v10 on a best effort basis, for researching odd servers")
+	flag.BoolVar(&scanDebugDecode, "debug-decode", false, "Trace each handshake field as it's decoded, with its offset, raw bytes and value, and where decoding failed to stderr")
+	flag.BoolVar(&scanDumpAuth, "dump-auth", false, "Write a hex and ASCII dump of each detected server's auth data to stderr, for looking into unusual auth plugins")
+	flag.BoolVar(&scanBanner, "banner", false, "Only read the server version from each host, faster for large scans but skips capabilities and auth data")
+	flag.BoolVar(&scanOnlyMySQL, "only-mysql", false, "Only output hosts MySQL was detected on when scanning multiple hosts, the summary still counts every host")
+	flag.BoolVar(&scanProgress, "progress", false, "Report how many hosts have been scanned to stderr during a bulk scan, the default when stderr is a terminal")
+	flag.BoolVar(&scanVerbose, "v", false, "Log each host scanned and its result to stderr")
+	flag.BoolVar(&scanVeryVerbose, "vv", false, "Log every dial, read and decode step to stderr, more detail than -v")
+	flag.BoolVar(&scanVersion, "version", false, "Print the version, commit and build date then exit")
+	// Invalid flags are a usage error like any other, rather than the flag package's exit code of 2
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(exitDetected)
+		}
+		os.Exit(exitUsage)
+	}
+
+	// Nothing else matters when asked for the version, not even invalid flags
+	if scanVersion {
+		writeVersion(os.Stdout)
+		os.Exit(exitDetected)
+	}
+
+	slog.SetDefault(newLogger(os.Stderr, scanVerbose, scanVeryVerbose))
+
+	switch scanFormat {
+	case formatText, formatJSON, formatJSONL, formatJSONArray, formatCSV, formatYAML, formatHosts:
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown output format '%s'\n", scanFormat)
+		flag.Usage()
+		os.Exit(exitUsage)
+	}
+
+	// Parse the template up front so a mistake in it is reported before anything is scanned
+	if scanTemplateText != "" {
+		if scanFormat != formatText {
+			fmt.Fprintf(os.Stderr, "-template can't be used with -format\n")
+			os.Exit(exitUsage)
+		}
+		tmpl, err := parseTemplate(scanTemplateText)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid template: %s\n", err)
+			os.Exit(exitUsage)
+		}
+		scanTemplate = tmpl
+	}
+
+	ports := []int{scanPort}
+	if scanPorts != "" {
+		parsed, err := parsePorts(scanPorts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid ports '%s': %s\n", scanPorts, err)
+			os.Exit(exitUsage)
+		}
+		ports = parsed
+	}
+
+	// Capabilities aren't decoded in banner mode so there is no telling whether SSL is advertised
+	if scanBanner && scanCheckTLS {
+		fmt.Fprintf(os.Stderr, "-check-tls can't be used with -banner\n")
+		os.Exit(exitUsage)
+	}
+	if scanBanner && scanForceDecode {
+		fmt.Fprintf(os.Stderr, "-force-decode can't be used with -banner\n")
+		os.Exit(exitUsage)
+	}
+	if scanDebugDecode && scanBanner {
+		fmt.Fprintf(os.Stderr, "-debug-decode can't be used with -banner\n")
+		os.Exit(exitUsage)
+	}
+	// Only the server version is read in banner mode, there is no auth data to dump
+	if scanDumpAuth && scanBanner {
+		fmt.Fprintf(os.Stderr, "-dump-auth can't be used with -banner\n")
+		os.Exit(exitUsage)
+	}
+	if scanSkipVerify && !scanTLS {
+		fmt.Fprintf(os.Stderr, "-tls-skip-verify can only be used with -tls\n")
+		os.Exit(exitUsage)
+	}
+	if scanTLS && (scanBanner || scanForceDecode || scanMaxBytes != 0 || scanDebugDecode) {
+		fmt.Fprintf(os.Stderr, "-tls can't be used with -banner, -force-decode, -maxbytes or -debug-decode\n")
+		os.Exit(exitUsage)
+	}
+	if scanHTTPProxy != "" {
+		if scanProxy != "" {
+			fmt.Fprintf(os.Stderr, "-http-proxy can't be used with -proxy\n")
+			os.Exit(exitUsage)
+		}
+		if _, _, err := net.SplitHostPort(scanHTTPProxy); err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid HTTP proxy '%s': %s\n", scanHTTPProxy, err)
+			os.Exit(exitUsage)
+		}
+	}
+	if scanSourceIP != "" && net.ParseIP(scanSourceIP) == nil {
+		fmt.Fprintf(os.Stderr, "Invalid source IP '%s'\n", scanSourceIP)
+		os.Exit(exitUsage)
+	}
+	if scanRunTimeout < 0 {
+		fmt.Fprintf(os.Stderr, "-run-timeout can't be negative\n")
+		os.Exit(exitUsage)
+	}
+	if scanMaxBytes < 0 {
+		fmt.Fprintf(os.Stderr, "-maxbytes can't be negative\n")
+		os.Exit(exitUsage)
+	}
+
+	if scanSRV != "" && (scanHostFile != "" || scanPorts != "" || strings.Contains(scanHost, "/")) {
+		fmt.Fprintf(os.Stderr, "-srv can't be used with -hostfile, -ports or a CIDR range\n")
+		os.Exit(exitUsage)
+	}
+	if scanSRV != "" && scanIPOnly {
+		fmt.Fprintf(os.Stderr, "-srv can't be used with -ip-only, looking up the SRV records is a DNS lookup\n")
+		os.Exit(exitUsage)
+	}
+
+	if scanSRV != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(scanTimeout))
+		targets, err := resolveSRV(ctx, net.DefaultResolver, scanSRV)
+		cancel()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to look up SRV records for '%s': %s\n", scanSRV, err)
+			os.Exit(exitConnectFailed)
+		}
+		scanTargets = targets
+		scanErrors = os.Stderr
+	} else if scanHostFile == "-" {
+		scanStdin = true
+		scanErrors = os.Stderr
+	} else if scanHostFile != "" {
+		targets, err := readHostFile(scanHostFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to read host file: %s\n", err)
+			os.Exit(exitUsage)
+		}
+		scanTargets = targets
+		scanErrors = os.Stderr
+	} else if strings.Contains(scanHost, "/") {
+		for _, port := range ports {
+			targets, err := expandCIDR(scanHost, port)
+			if err == nil && len(scanTargets)+len(targets) > maxCIDRTargets {
+				err = ErrorRangeTooLarge
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Invalid CIDR range '%s': %s\n", scanHost, err)
+				os.Exit(exitUsage)
+			}
+			scanTargets = append(scanTargets, targets...)
+		}
+	} else if scanPorts != "" {
+		// Report every port probed, the ones without a MySQL handshake go to stderr with the reason
+		scanTargets = expandPorts(scanHost, ports)
+		scanErrors = os.Stderr
+	}
+
+	if scanSeed != 0 && !scanRandomize {
+		fmt.Fprintf(os.Stderr, "-seed can only be used with -randomize\n")
+		os.Exit(exitUsage)
+	}
+	if scanRandomize {
+		// Hosts from stdin are scanned as they arrive, so there is no list to shuffle
+		if scanStdin {
+			fmt.Fprintf(os.Stderr, "-randomize can't be used with -hostfile -\n")
+			os.Exit(exitUsage)
+		}
+		seed := scanSeed
+		if seed == 0 {
+			seed = rand.Uint64()
+		}
+		slog.Info("Shuffling targets", "targets", len(scanTargets), "seed", seed)
+		shuffleTargets(scanTargets, seed)
+	}
+
+	// A socket is a single local server, only the options for a single host in text or json make sense
+	if scanSocket != "" && (scanTargets != nil || scanStdin || recordsFailures(scanFormat) || scanCompareHosts != "" || scanAuth != "" || scanTLS || scanBanner || scanForceDecode || scanMaxBytes != 0 || scanDebugDecode || scanSourceIP != "") {
+		fmt.Fprintf(os.Stderr, "-socket can only be used in text or json format without multiple hosts, -compare, -auth, -tls, -banner, -force-decode, -maxbytes, -debug-decode or -source-ip\n")
+		os.Exit(exitUsage)
+	}
+
+	// JSON lines and CSV always emit a record per host, so a single host is scanned like any other target list
+	if scanTargets == nil && !scanStdin && recordsFailures(scanFormat) {
+		scanTargets = []string{scanHost}
+		scanErrors = os.Stderr
+	}
+
+	if scanAuth != "" && (scanTargets != nil || scanStdin) {
+		fmt.Fprintf(os.Stderr, "-auth can only be used with a single -host in text or json format\n")
+		os.Exit(exitUsage)
+	}
+	if scanCompareHosts != "" {
+		hosts := strings.Split(scanCompareHosts, ",")
+		if len(hosts) != 2 || hosts[0] == "" || hosts[1] == "" {
+			fmt.Fprintf(os.Stderr, "-compare must be two hosts as hostA,hostB\n")
+			os.Exit(exitUsage)
+		}
+		if scanBanner || scanTemplate != nil {
+			fmt.Fprintf(os.Stderr, "-compare can't be used with -banner or -template\n")
+			os.Exit(exitUsage)
+		}
+		scanCompare = hosts
+	}
+	if scanAuth != "" && !strings.Contains(scanAuth, ":") {
+		fmt.Fprintf(os.Stderr, "-auth must be user:pass\n")
+		os.Exit(exitUsage)
+	}
+}
+
+// Logger for the -v and -vv verbosity, by default only warnings and errors are logged
+// Logs are plain text on stderr so they never mix into the machine readable formats on stdout
+func newLogger(w io.Writer, verbose, veryVerbose bool) *slog.Logger {
+	level := slog.LevelWarn
+	if veryVerbose {
+		level = slog.LevelDebug
+	} else if verbose {
+		level = slog.LevelInfo
+	}
+
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{Level: level}))
+}
+
+// Write the detected handshake to w in the given output format
+func writeResult(w io.Writer, sql *mysqlscan.MySQLv10, format string) error {
+	switch format {
+	case formatJSON:
+		return json.NewEncoder(w).Encode(sql)
+	case formatYAML:
+		return writeYAML(w, sql)
+	}
+
+	_, err := fmt.Fprintf(w, "Detected MySQL:\n%s\n", sql.String())
+	return err
+}
+
+// Scan every target using concurrency workers, hosts that aren't running MySQL have their error written to errw
+// unless the format records errors itself
+// Results are written as each host finishes, labeled with the host since they won't be in target order
+// Once opts.count servers are detected the rest of the scan is cancelled and only those hosts are reported
+// Cancelling ctx stops any more hosts being started, the hosts in progress finish and are reported
+// Returns the summary of every host scanned
+func scanAll(ctx context.Context, w, errw io.Writer, targets []string, opts scanOptions, format string) (*ScanSummary, error) {
+	summary, err := scan(ctx, w, errw, func(ctx context.Context, jobs chan<- string) error {
+		for _, host := range targets {
+			select {
+			case jobs <- host:
+			case <-ctx.Done():
+				return nil
+			}
+		}
+		return nil
+	}, opts, format)
+
+	// Every target is known up front, so the ones never sent to a worker count as unscanned too
+	summary.Unscanned = len(targets) - summary.Total
+	return summary, err
+}
+
+// Scan targets read one per line from r like scanAll, each host is scanned as soon as its line is read
+// so results stream out while r is still being written to, such as a pipe on stdin
+func scanReader(ctx context.Context, w, errw io.Writer, r io.Reader, opts scanOptions, format string) (*ScanSummary, error) {
+	return scan(ctx, w, errw, func(ctx context.Context, jobs chan<- string) error {
+		return sendTargets(ctx, r, jobs)
+	}, opts, format)
+}
+
+// Scan every target sent by feed, feed must return once the targets run out or ctx is done
+// An error from feed is returned after the hosts it did send are reported
+// Cancelling ctx only stops feed, the workers aren't cancelled so the hosts they already have are reported
+// Reaching opts.deadline or opts.runTimeout stops feed and the workers, the hosts in progress are counted as unscanned
+func scan(ctx context.Context, w, errw io.Writer, feed func(ctx context.Context, jobs chan<- string) error, opts scanOptions, format string) (*ScanSummary, error) {
+	var workCtx context.Context
+	var cancel context.CancelFunc
+	if deadline := opts.runDeadline(time.Now()); !deadline.IsZero() {
+		workCtx, cancel = context.WithDeadline(context.Background(), deadline)
+	} else {
+		workCtx, cancel = context.WithCancel(context.Background())
+	}
+	defer cancel()
+	feedCtx, stopFeed := context.WithCancel(ctx)
+	defer stopFeed()
+	context.AfterFunc(workCtx, stopFeed)
+
+	// feedErr is safe to read once feedDone is closed. The workers stop at the count or deadline without
+	// waiting for jobs to be closed, stopping the feed then too so waiting on it doesn't hold up the scan
+	var feedErr error
+	jobs := make(chan string)
+	feedDone := make(chan struct{})
+	go func() {
+		defer close(feedDone)
+		feedErr = feed(feedCtx, jobs)
+		close(jobs)
+	}()
+
+	summary := newScanSummary()
+	var out resultWriter
+	var writeErr error
+	if opts.template != nil {
+		out = &templateResultWriter{w: w, tmpl: opts.template}
+	} else {
+		out, writeErr = newResultWriter(w, format)
+	}
+	for result := range scanPool(workCtx, jobs, opts) {
+		// Hosts still in progress when the count or deadline was reached were cut short, drain them without reporting
+		if workCtx.Err() != nil {
+			summary.Unscanned++
+			continue
+		}
+		summary.Add(&result)
+		if result.Err != nil && opts.onlyMySQL {
+			continue
+		}
+
+		// JSON lines and CSV record failures alongside detections, every other format only writes detections
+		if result.Err != nil && !recordsFailures(format) {
+			fmt.Fprintf(errw, "%s: %s\n", result.Host, result.Err)
+			continue
+		}
+		// Keep draining after a write error so the workers can finish
+		if writeErr == nil {
+			writeErr = out.Write(&result)
+		}
+		if opts.count > 0 && summary.MySQL >= opts.count {
+			cancel()
+		}
+	}
+	if writeErr == nil {
+		writeErr = out.Flush()
+	}
+	<-feedDone
+	if writeErr == nil && feedErr != nil {
+		return summary, fmt.Errorf("%w: %w", ErrorReadTargets, feedErr)
+	}
+
+	return summary, writeErr
+}
+
+// Context cancelled on the first SIGINT or SIGTERM so a bulk scan can stop starting hosts and still write
+// the results and summary of the ones it scanned, a second signal exits straight away as normal
+// Call the returned func once the scan is done to stop catching the signals
+func interruptContext(stderr io.Writer) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	context.AfterFunc(ctx, func() {
+		stop()
+		fmt.Fprintf(stderr, "Interrupted, finishing the hosts in progress, interrupt again to exit now\n")
+	})
+
+	return ctx, stop
+}
+
+// Context for scanning a single host or the -compare pair, done at opts.deadline or opts.runTimeout if either is set
+func runContext(opts scanOptions) (context.Context, context.CancelFunc) {
+	if deadline := opts.runDeadline(time.Now()); !deadline.IsZero() {
+		return context.WithDeadline(context.Background(), deadline)
+	}
+
+	return context.WithCancel(context.Background())
+}
+
+// Write whether the detected server advertises SSL, returning false if it doesn't
+func checkTLS(w io.Writer, sql *mysqlscan.MySQLv10) bool {
+	if !sql.SupportsTLS() {
+		fmt.Fprintf(w, "SSL is NOT advertised by the server\n")
+		return false
+	}
+
+	fmt.Fprintf(w, "SSL is advertised by the server\n")
+	return true
+}
+
+// Try logging in to host with user:pass credentials, writing whether it worked and returning false if not
+func checkLogin(w io.Writer, host, credentials string, opts scanOptions) bool {
+	user, pass, _ := strings.Cut(credentials, ":")
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	if err := mysqlscan.LoginContext(ctx, opts.dialer, host, user, pass); err != nil {
+		fmt.Fprintf(w, "Login as %s failed: %s\n", user, err)
+		return false
+	}
+
+	fmt.Fprintf(w, "Login as %s succeeded\n", user)
+	return true
 }
 
 func main() {
 	parseCommandLine()
+	os.Exit(run(os.Stderr))
+}
+
+// Scan the hosts from the parsed command line, writing errors and the summary to stderr
+// Returns the exit code describing the outcome
+func run(stderr io.Writer) int {
+	opts := scanOptions{
+		concurrency:    scanConcurrency,
+		timeout:        time.Duration(scanTimeout),
+		connectTimeout: time.Duration(scanConnTimeout),
+		readTimeout:    time.Duration(scanReadTimeout),
+		retries:        scanRetries,
+		banner:         scanBanner,
+		ipOnly:         scanIPOnly,
+		tls:            scanTLS,
+		tlsSkipVerify:  scanSkipVerify,
+		forceDecode:    scanForceDecode,
+		maxBytes:       scanMaxBytes,
+		onlyMySQL:      scanOnlyMySQL,
+		count:          scanCount,
+		template:       scanTemplate,
+		deadline:       scanDeadline,
+		runTimeout:     scanRunTimeout,
+		rate:           scanRate,
+		jitter:         scanJitter,
+	}
+	if scanDebugDecode {
+		opts.debugDecode = &decodeDebugger{w: stderr}
+	}
+	if scanDumpAuth {
+		opts.dumpAuth = stderr
+	}
+	// Every connection leaves from the source IP, including the one to a proxy
+	var source mysqlscan.ContextDialer
+	if scanSourceIP != "" {
+		dialer, err := mysqlscan.NewSourceDialer(scanSourceIP)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid source IP: %s\n", err)
+			return exitUsage
+		}
+		source, opts.dialer = dialer, dialer
+	}
+	if scanProxy != "" {
+		dialer, err := mysqlscan.NewSOCKS5Dialer(scanProxy)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid proxy: %s\n", err)
+			return exitUsage
+		}
+		dialer.Forward = source
+		opts.dialer = dialer
+	} else if scanHTTPProxy != "" {
+		opts.dialer = &mysqlscan.HTTPProxyDialer{ProxyAddr: scanHTTPProxy, Forward: source}
+	} else if !scanNoDNSCache {
+		// Only without a proxy, resolving locally would stop the proxy from doing the lookup
+		opts.dialer = mysqlscan.NewCachingDialer(source)
+	}
+
+	out, err := createOutput(scanOutput, scanGzip)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create output file: %s\n", err)
+		return exitOutputFailed
+	}
+
+	if scanCompare != nil {
+		defer out.Close()
+		ctx, cancel := runContext(opts)
+		defer cancel()
+		return compare(ctx, out, stderr, scanCompare[0], scanCompare[1], opts)
+	}
+
+	if scanTargets != nil || scanStdin {
+		stopProgress := func() {}
+		if scanProgress || isTerminal(os.Stderr) {
+			opts.progress = newProgress(len(scanTargets))
+			stopProgress = opts.progress.start(stderr, progressInterval)
+		}
 
-	if sql, err := DetectMySQL(scanHost, scanTimeout); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", err)
-		os.Exit(1)
+		ctx, stopInterrupt := interruptContext(stderr)
+		var summary *ScanSummary
+		if scanStdin {
+			summary, err = scanReader(ctx, out, scanErrors, os.Stdin, opts, scanFormat)
+		} else {
+			summary, err = scanAll(ctx, out, scanErrors, scanTargets, opts, scanFormat)
+		}
+		stopInterrupt()
+		stopProgress()
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil && !errors.Is(err, ErrorReadTargets) {
+			fmt.Fprintf(stderr, "Failed to scan targets: %s\n", err)
+			return exitOutputFailed
+		}
+
+		// Summary goes to stderr so it doesn't get mixed into the per host results
+		summary.Write(stderr, scanFormat)
+		if summary.Unscanned > 0 {
+			fmt.Fprintf(stderr, "Scan stopped early, %d targets weren't scanned\n", summary.Unscanned)
+		}
+		// The hosts read before the targets failed are still reported, but the input was bad
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return exitUsage
+		}
+
+		if summary.MySQL == 0 {
+			fmt.Fprintf(stderr, "No MySQL servers detected\n")
+			if summary.Reachable == 0 {
+				return exitConnectFailed
+			}
+			return exitNotMySQL
+		}
+		if scanCheckTLS && summary.NoTLS > 0 {
+			fmt.Fprintf(stderr, "SSL is NOT advertised by %d of %d detected servers\n", summary.NoTLS, summary.MySQL)
+			return exitCheckFailed
+		}
+		if scanFailOnEOL && summary.EndOfLife > 0 {
+			fmt.Fprintf(stderr, "%d of %d detected servers are running an end of life release\n", summary.EndOfLife, summary.MySQL)
+			return exitCheckFailed
+		}
+		return exitDetected
+	}
+
+	ctx, cancel := runContext(opts)
+	defer cancel()
+	var sql *mysqlscan.MySQLv10
+	if scanSocket != "" {
+		sql, err = detectSocket(scanSocket, opts.timeout)
+		if err == nil && opts.dumpAuth != nil {
+			io.WriteString(opts.dumpAuth, authDump(scanSocket, sql))
+		}
 	} else {
-		fmt.Printf("Detected MySQL:\n%s\n", sql.String())
+		sql, err = detectWithRetry(ctx, scanHost, opts)
+	}
+	if err != nil {
+		out.Close()
+		fmt.Fprintf(stderr, "%s\n", err)
+		return detectExitCode(err)
+	}
+	switch {
+	case scanTemplate != nil:
+		err = scanTemplate.Execute(out, &mysqlscan.ScanResult{Host: cmp.Or(scanSocket, scanHost), MySQL: sql})
+	case scanFormat == formatHosts:
+		_, err = fmt.Fprintln(out, cmp.Or(scanSocket, scanHost))
+	default:
+		err = writeResult(out, sql, scanFormat)
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to write output: %s\n", err)
+		return exitOutputFailed
 	}
+	if scanCheckTLS && !checkTLS(stderr, sql) {
+		return exitCheckFailed
+	}
+	if scanAuth != "" && !checkLogin(stderr, scanHost, scanAuth, opts) {
+		return exitCheckFailed
+	}
+	if scanFailOnEOL && sql.IsEndOfLifeAt(endOfLifeNow()) {
+		eol, _ := sql.EndOfLife()
+		fmt.Fprintf(stderr, "MySQL %s reached end of life on %s\n", sql.ServerVersion, eol.Format(time.DateOnly))
+		return exitCheckFailed
+	}
+
+	return exitDetected
+}
+
+// Exit code for a host MySQL wasn't detected on
+func detectExitCode(err error) int {
+	switch {
+	case errors.Is(err, ErrorNotIP):
+		return exitUsage
+	case errors.Is(err, mysqlscan.ErrorConnect):
+		return exitConnectFailed
+	}
+
+	return exitNotMySQL
 }
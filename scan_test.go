@@ -0,0 +1,895 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Packet capture of a MySQL v8.0.21 handshake
+var normalHandshake = []byte{
+	0x4a, 0x00, 0x00, 0x00, 0x0a, 0x38, 0x2e, 0x30, 0x2e, 0x32, 0x31, 0x00, 0x10, 0x00, 0x00, 0x00,
+	0x38, 0x63, 0x7a, 0x7b, 0x5e, 0x07, 0x6a, 0x39, 0x00, 0xff, 0xff, 0xff, 0x02, 0x00, 0xff, 0xc7,
+	0x15, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x45, 0x38, 0x35, 0x48, 0x50,
+	0x68, 0x4c, 0x5c, 0x62, 0x42, 0x0b, 0x4e, 0x00, 0x63, 0x61, 0x63, 0x68, 0x69, 0x6e, 0x67, 0x5f,
+	0x73, 0x68, 0x61, 0x32, 0x5f, 0x70, 0x61, 0x73, 0x73, 0x77, 0x6f, 0x72, 0x64, 0x00,
+}
+
+func TestMain(m *testing.M) {
+	// Child process started by runMain
+	if args, ok := os.LookupEnv("MYSQL_SCAN_MAIN_ARGS"); ok {
+		flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+		os.Args = append([]string{os.Args[0]}, strings.Split(args, "\n")...)
+		main()
+		os.Exit(0)
+	}
+
+	// Keep the per host logs out of the test output, tests that check them set their own logger
+	slog.SetDefault(newLogger(io.Discard, false, false))
+	os.Exit(m.Run())
+}
+
+// Run main with args in a child process since it exits, returning what it wrote to stdout
+func runMain(t *testing.T, args ...string) ([]byte, error) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "MYSQL_SCAN_MAIN_ARGS="+strings.Join(args, "\n"))
+	return cmd.Output()
+}
+
+// Listen on a local port and write packet to every connection, returning the host:port to dial
+func serveHandshake(t *testing.T, packet []byte) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.Write(packet)
+			conn.Close()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+// Host:port with nothing listening on it, so connections are refused
+func closedPort(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	ln.Close()
+
+	return ln.Addr().String()
+}
+
+func TestWriteResultJSON(t *testing.T) {
+	sql := mysqlscan.MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeResult(&buf, &sql, formatJSON); err != nil {
+		t.Fatalf("Failed to write JSON: %s", err)
+	}
+
+	// AuthData, its parts and RawPacket should come back as hex strings, everything else maps straight onto the struct
+	var out struct {
+		mysqlscan.MySQLv10
+		AuthData      string `json:"auth_data"`
+		AuthDataPart1 string `json:"auth_data_part1"`
+		AuthDataPart2 string `json:"auth_data_part2"`
+		Raw           string `json:"raw"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+		t.Fatalf("Failed to unmarshal JSON output: %s", err)
+	}
+
+	authData, err := hex.DecodeString(out.AuthData)
+	if err != nil {
+		t.Fatalf("AuthData wasn't hex encoded '%s': %s", out.AuthData, err)
+	}
+	out.MySQLv10.AuthData = authData
+	if out.MySQLv10.AuthDataPart1, err = hex.DecodeString(out.AuthDataPart1); err != nil {
+		t.Fatalf("AuthDataPart1 wasn't hex encoded '%s': %s", out.AuthDataPart1, err)
+	}
+	if out.MySQLv10.AuthDataPart2, err = hex.DecodeString(out.AuthDataPart2); err != nil {
+		t.Fatalf("AuthDataPart2 wasn't hex encoded '%s': %s", out.AuthDataPart2, err)
+	}
+	if out.MySQLv10.RawPacket, err = hex.DecodeString(out.Raw); err != nil {
+		t.Fatalf("Raw packet wasn't hex encoded '%s': %s", out.Raw, err)
+	}
+
+	if !reflect.DeepEqual(out.MySQLv10, sql) {
+		t.Errorf("JSON didn't round-trip\ngot:  %+v\nwant: %+v", out.MySQLv10, sql)
+	}
+}
+
+func TestScanAllConcurrent(t *testing.T) {
+	var targets []string
+	for i := 0; i < 5; i++ {
+		targets = append(targets, serveHandshake(t, normalHandshake))
+	}
+	refused := closedPort(t)
+	targets = append(targets, refused)
+
+	var out, errOut bytes.Buffer
+	summary, err := scanAll(context.Background(), &out, &errOut, targets, scanOptions{concurrency: 3, timeout: time.Second}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.MySQL != 5 {
+		t.Errorf("Expected 5 detected hosts, got %d", summary.MySQL)
+	}
+
+	// Every host should get exactly one line, on stdout if detected otherwise on stderr
+	for _, host := range targets[:5] {
+		if n := strings.Count(out.String(), host+": "); n != 1 {
+			t.Errorf("Expected one result line for %s, got %d", host, n)
+		}
+	}
+	if !strings.HasPrefix(errOut.String(), refused+": ") || strings.Count(errOut.String(), "\n") != 1 {
+		t.Errorf("Expected one error line for %s, got: %s", refused, errOut.String())
+	}
+}
+
+func TestScanAllJSONLines(t *testing.T) {
+	detectedHost := serveHandshake(t, normalHandshake)
+	refused := closedPort(t)
+
+	var out, errOut bytes.Buffer
+	if _, err := scanAll(context.Background(), &out, &errOut, []string{detectedHost, refused}, scanOptions{concurrency: 2, timeout: time.Second}, formatJSONL); err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 JSON records, got %d: %s", len(lines), out.String())
+	}
+
+	type record struct {
+		Host      string           `json:"host"`
+		Success   bool             `json:"success"`
+		Error     string           `json:"error"`
+		DialError string           `json:"dial_error"`
+		MySQL     *json.RawMessage `json:"mysql"`
+	}
+	records := map[string]record{}
+	for _, line := range lines {
+		var record record
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("Failed to unmarshal JSON record '%s': %s", line, err)
+		}
+		records[record.Host] = record
+	}
+
+	if r := records[detectedHost]; !r.Success || r.Error != "" || r.MySQL == nil {
+		t.Errorf("Expected successful record for %s: %+v", detectedHost, r)
+	}
+	if r := records[refused]; r.Success || r.Error == "" || r.DialError != "refused" || r.MySQL != nil {
+		t.Errorf("Expected failed record for %s: %+v", refused, r)
+	}
+}
+
+func TestScanAllCSV(t *testing.T) {
+	// Re-encode the capture with a version string that needs quoting
+	sql := mysqlscan.MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	sql.ServerVersion = "8.0.21-log,custom"
+	packet, err := sql.Encode()
+	if err != nil {
+		t.Fatalf("Failed to encode handshake: %s", err)
+	}
+	detectedHost := serveHandshake(t, packet)
+	refused := closedPort(t)
+
+	var out bytes.Buffer
+	if _, err := scanAll(context.Background(), &out, io.Discard, []string{detectedHost, refused}, scanOptions{concurrency: 2, timeout: time.Second}, formatCSV); err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+
+	rows, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatalf("Failed to parse CSV output: %s", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("Expected a header and 2 rows, got %d: %q", len(rows), rows)
+	}
+	if !reflect.DeepEqual(rows[0], csvHeader) {
+		t.Errorf("Header didn't match expected: %q", rows[0])
+	}
+
+	records := map[string][]string{}
+	for _, row := range rows[1:] {
+		records[row[0]] = row
+	}
+	expected := map[string][]string{
+		detectedHost: {detectedHost, "true", "true", mysqlscan.FlavorMySQL, "8.0.21-log,custom", "true", "caching_sha2_password"},
+		refused:      {refused, "false", "false", "", "", "", ""},
+	}
+	for host, row := range expected {
+		if !reflect.DeepEqual(records[host], row) {
+			t.Errorf("Row for %s didn't match expected\ngot:  %q\nwant: %q", host, records[host], row)
+		}
+	}
+}
+
+func TestScanAllBanner(t *testing.T) {
+	// Without SSL so a full decode would count it in NoTLS
+	noSSL := append([]byte{}, normalHandshake...)
+	noSSL[26] &^= 0x08
+	host := serveHandshake(t, noSSL)
+
+	var out bytes.Buffer
+	summary, err := scanAll(context.Background(), &out, io.Discard, []string{host}, scanOptions{timeout: time.Second, banner: true}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.MySQL != 1 || summary.NoTLS != 0 {
+		t.Errorf("Expected 1 detected host not counted as missing SSL, got %+v", summary)
+	}
+	if expected := host + ": {ProtocolVersion:10 ServerVersion:8.0.21 Flavor:MySQL Latency:"; !strings.HasPrefix(out.String(), expected) {
+		t.Errorf("Banner output didn't match expected '%s': %s", expected, out.String())
+	}
+}
+
+func TestScanAllCount(t *testing.T) {
+	targets := []string{
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+	}
+
+	// One worker so the third host is never reported, whether it's cancelled before or during its scan
+	var out bytes.Buffer
+	summary, err := scanAll(context.Background(), &out, io.Discard, targets, scanOptions{concurrency: 1, timeout: time.Second, count: 2}, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 2 || summary.MySQL != 2 || summary.Unscanned != 1 {
+		t.Errorf("Expected the scan to stop after 2 detected hosts, got %+v", summary)
+	}
+	if lines := strings.Count(out.String(), "\n"); lines != 2 {
+		t.Errorf("Expected 2 records, got %d:\n%s", lines, out.String())
+	}
+}
+
+func TestScanAllInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Interrupt the scan as soon as the second host is connected to, then finish its handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		cancel()
+		time.Sleep(50 * time.Millisecond)
+		conn.Write(normalHandshake)
+		conn.Close()
+	}()
+
+	targets := []string{
+		serveHandshake(t, normalHandshake),
+		ln.Addr().String(),
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+	}
+
+	// One worker so nothing after the interrupted host has been started
+	var out bytes.Buffer
+	summary, err := scanAll(ctx, &out, io.Discard, targets, scanOptions{concurrency: 1, timeout: time.Second}, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 2 || summary.MySQL != 2 || summary.Unscanned != 2 {
+		t.Errorf("Expected only the 2 hosts started before the interrupt, got %+v", summary)
+	}
+	if !strings.Contains(out.String(), ln.Addr().String()) || strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("Expected records for the 2 hosts started before the interrupt:\n%s", out.String())
+	}
+}
+
+func TestScanAllDeadline(t *testing.T) {
+	targets := []string{serveHandshake(t, normalHandshake), serveHandshake(t, normalHandshake), serveHandshake(t, normalHandshake)}
+
+	start := time.Now()
+	var out bytes.Buffer
+	opts := scanOptions{concurrency: 1, timeout: time.Second, deadline: time.Now().Add(-time.Second)}
+	summary, err := scanAll(context.Background(), &out, io.Discard, targets, opts, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Scan past its deadline took %s", elapsed)
+	}
+	if summary.Total != 0 || summary.Unscanned != len(targets) {
+		t.Errorf("Expected every host to be unscanned, got %+v", summary)
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected no records past the deadline:\n%s", out.String())
+	}
+}
+
+// Server that accepts connections and never sends anything, so each host takes its whole timeout
+func serveSilent(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// Held open until the scanner gives up and closes its end
+			go func() {
+				io.Copy(io.Discard, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestScanAllRunTimeout(t *testing.T) {
+	targets := []string{serveHandshake(t, normalHandshake), serveHandshake(t, normalHandshake)}
+	for range 10 {
+		targets = append(targets, serveSilent(t))
+	}
+
+	// Each slow host would take its whole 5s timeout, the run is cut off well before the first one finishes
+	start := time.Now()
+	var out bytes.Buffer
+	opts := scanOptions{concurrency: 1, timeout: 5 * time.Second, runTimeout: 200 * time.Millisecond}
+	summary, err := scanAll(context.Background(), &out, io.Discard, targets, opts, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Scan ran past its run timeout, took %s", elapsed)
+	}
+	if summary.Total != 2 || summary.MySQL != 2 || summary.Unscanned != len(targets)-2 {
+		t.Errorf("Expected the two fast hosts scanned and the rest unscanned, got %+v", summary)
+	}
+	if strings.Count(out.String(), "\n") != 2 {
+		t.Errorf("Expected only the completed hosts' records:\n%s", out.String())
+	}
+
+	// The earlier of the deadline and the run timeout wins
+	deadline := start.Add(time.Minute)
+	if got := (scanOptions{deadline: deadline, runTimeout: time.Hour}).runDeadline(start); !got.Equal(deadline) {
+		t.Errorf("Expected the deadline, got %s", got)
+	}
+	if got := (scanOptions{deadline: deadline, runTimeout: time.Second}).runDeadline(start); !got.Equal(start.Add(time.Second)) {
+		t.Errorf("Expected the run timeout, got %s", got)
+	}
+}
+
+func TestScanAllRunTimeoutReadTimeout(t *testing.T) {
+	// The run timeout is far later than the read timeout, which still ends the silent host's attempt
+	start := time.Now()
+	opts := scanOptions{concurrency: 1, timeout: 10 * time.Second, readTimeout: 100 * time.Millisecond, runTimeout: 4 * time.Second}
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, []string{serveSilent(t)}, opts, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Read timeout took %s, expected about %s", elapsed, opts.readTimeout)
+	}
+	if summary.Total != 1 || summary.Unscanned != 0 {
+		t.Errorf("Expected the host to be scanned and time out, got %+v", summary)
+	}
+}
+
+func TestScanAllRate(t *testing.T) {
+	targets := []string{
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+	}
+
+	// The limiter is shared so more workers than targets still can't go faster than the rate
+	opts := scanOptions{concurrency: 4, timeout: time.Second, rate: 50, jitter: 5 * time.Millisecond}
+	start := time.Now()
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, targets, opts, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.MySQL != 4 {
+		t.Errorf("Expected 4 detected hosts, got %+v", summary)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("4 hosts at 50 per second took %s, expected at least 60ms", elapsed)
+	}
+}
+
+func TestScanReader(t *testing.T) {
+	hosts := []string{serveHandshake(t, normalHandshake), serveHandshake(t, normalHandshake), closedPort(t)}
+	stdin := strings.NewReader("# masscan open ports\n" + hosts[0] + "\n\n" + hosts[1] + "\n" + hosts[2])
+
+	var out bytes.Buffer
+	summary, err := scanReader(context.Background(), &out, io.Discard, stdin, scanOptions{concurrency: 2, timeout: time.Second}, formatJSONL)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 3 || summary.MySQL != 2 {
+		t.Errorf("Expected 3 hosts scanned with 2 detected, got %+v", summary)
+	}
+	for _, host := range hosts {
+		if !strings.Contains(out.String(), `"host":"`+host+`"`) {
+			t.Errorf("Missing record for '%s':\n%s", host, out.String())
+		}
+	}
+}
+
+func TestScanReaderIdle(t *testing.T) {
+	host := serveHandshake(t, normalHandshake)
+
+	// Stdin left open with nothing more written, like -hostfile - fed by a process that's gone quiet
+	scanIdle := func(ctx context.Context, opts scanOptions, lines string) (*ScanSummary, error) {
+		r, w := io.Pipe()
+		t.Cleanup(func() { w.Close() })
+		go io.WriteString(w, lines)
+
+		type scanned struct {
+			summary *ScanSummary
+			err     error
+		}
+		done := make(chan scanned, 1)
+		go func() {
+			summary, err := scanReader(ctx, io.Discard, io.Discard, r, opts, formatJSONL)
+			done <- scanned{summary, err}
+		}()
+		select {
+		case s := <-done:
+			return s.summary, s.err
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Scan didn't finish while stdin was idle")
+			return nil, nil
+		}
+	}
+
+	// Reaching the count finishes the scan without waiting for another line
+	summary, err := scanIdle(context.Background(), scanOptions{concurrency: 2, timeout: time.Second, count: 1}, host+"\n")
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.MySQL != 1 {
+		t.Errorf("Expected 1 detected host, got %+v", summary)
+	}
+
+	// So does the run timeout when nothing was ever sent
+	start := time.Now()
+	summary, err = scanIdle(context.Background(), scanOptions{concurrency: 2, timeout: time.Second, runTimeout: 50 * time.Millisecond}, "")
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 0 || time.Since(start) > time.Second {
+		t.Errorf("Expected the run timeout to end the scan after 50ms, got %+v after %s", summary, time.Since(start))
+	}
+
+	// And an interrupt
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	summary, err = scanIdle(ctx, scanOptions{concurrency: 2, timeout: time.Second}, "")
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 0 {
+		t.Errorf("Expected nothing scanned, got %+v", summary)
+	}
+}
+
+func TestScanAllOnlyMySQL(t *testing.T) {
+	detectedHost := serveHandshake(t, normalHandshake)
+	targets := []string{detectedHost, serveHandshake(t, []byte("SSH-2.0-OpenSSH_8.9\r\n")), closedPort(t)}
+
+	for _, format := range []string{formatText, formatJSONL, formatCSV} {
+		var out, errOut bytes.Buffer
+		summary, err := scanAll(context.Background(), &out, &errOut, targets, scanOptions{concurrency: 3, timeout: time.Second, onlyMySQL: true}, format)
+		if err != nil {
+			t.Fatalf("%s: failed to scan targets: %s", format, err)
+		}
+		if summary.Total != 3 || summary.MySQL != 1 {
+			t.Errorf("%s: expected every host in the summary, got %+v", format, summary)
+		}
+		for _, host := range targets[1:] {
+			if strings.Contains(out.String(), host) || strings.Contains(errOut.String(), host) {
+				t.Errorf("%s: expected no output for '%s':\n%s%s", format, host, out.String(), errOut.String())
+			}
+		}
+		if !strings.Contains(out.String(), detectedHost) {
+			t.Errorf("%s: missing output for '%s':\n%s", format, detectedHost, out.String())
+		}
+	}
+}
+
+func TestScanAllHostsFormat(t *testing.T) {
+	detected := []string{serveHandshake(t, normalHandshake), serveHandshake(t, normalHandshake)}
+	notMySQL := serveHandshake(t, []byte("SSH-2.0-OpenSSH_8.9\r\n"))
+	refused := closedPort(t)
+
+	var out bytes.Buffer
+	summary, err := scanAll(context.Background(), &out, io.Discard, []string{detected[0], notMySQL, refused, detected[1]}, scanOptions{concurrency: 4, timeout: time.Second}, formatHosts)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 4 || summary.MySQL != 2 {
+		t.Errorf("Expected every host in the summary, got %+v", summary)
+	}
+
+	// Only the detected hosts, one per line in whatever order they finished
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	sort.Strings(lines)
+	expected := append([]string{}, detected...)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("Expected only the detected hosts %v, got:\n%s", expected, out.String())
+	}
+}
+
+func TestScanAllJSONArray(t *testing.T) {
+	var targets []string
+	for range 20 {
+		targets = append(targets, serveHandshake(t, normalHandshake))
+	}
+	targets = append(targets, closedPort(t))
+
+	var out bytes.Buffer
+	if _, err := scanAll(context.Background(), &out, io.Discard, targets, scanOptions{concurrency: 4, timeout: time.Second}, formatJSONArray); err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+
+	// Every host is an element, failures included like jsonl
+	var records []struct {
+		Host    string `json:"host"`
+		Success bool   `json:"success"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil {
+		t.Fatalf("Output isn't a valid JSON array: %s\n%s", err, out.String())
+	}
+	hosts := map[string]bool{}
+	for _, record := range records {
+		hosts[record.Host] = record.Success
+	}
+	if len(records) != len(targets) || len(hosts) != len(targets) || hosts[targets[len(targets)-1]] {
+		t.Errorf("Expected a record for each of the %d targets, got %d:\n%s", len(targets), len(records), out.String())
+	}
+
+	// Nothing scanned is still a valid empty array
+	out.Reset()
+	if _, err := scanAll(context.Background(), &out, io.Discard, nil, scanOptions{timeout: time.Second}, formatJSONArray); err != nil {
+		t.Fatalf("Failed to scan no targets: %s", err)
+	}
+	if err := json.Unmarshal(out.Bytes(), &records); err != nil || len(records) != 0 {
+		t.Errorf("Expected an empty array, got %q: %v", out.String(), err)
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	withSSL := serveHandshake(t, normalHandshake)
+
+	// Clear CLIENT_SSL (0x0800) in capability_flags_1 at offset 25
+	noSSL := append([]byte{}, normalHandshake...)
+	noSSL[26] &^= 0x08
+	withoutSSL := serveHandshake(t, noSSL)
+
+	tests := []struct {
+		name string
+		host string
+		pass bool
+	}{
+		{name: "SSL advertised", host: withSSL, pass: true},
+		{name: "SSL not advertised", host: withoutSSL, pass: false},
+	}
+
+	for _, test := range tests {
+		sql, err := mysqlscan.DetectMySQLTimeout(test.host, time.Second)
+		if err != nil {
+			t.Fatalf("Failed to detect MySQL '%s': %s", test.name, err)
+		}
+
+		var out bytes.Buffer
+		if pass := checkTLS(&out, sql); pass != test.pass {
+			t.Errorf("TLS check didn't match expected '%s': %s", test.name, out.String())
+		}
+	}
+
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, []string{withSSL, withoutSSL}, scanOptions{concurrency: 2, timeout: time.Second}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.MySQL != 2 || summary.NoTLS != 1 {
+		t.Errorf("Expected 1 of 2 detected hosts without SSL, got %+v", summary)
+	}
+}
+
+func TestTimeoutFlag(t *testing.T) {
+	tests := []struct {
+		value   string
+		timeout time.Duration
+		err     bool
+	}{
+		{value: "250ms", timeout: 250 * time.Millisecond},
+		{value: "2s", timeout: 2 * time.Second},
+		{value: "3", timeout: 3 * time.Second},
+		{value: "1m30s", timeout: 90 * time.Second},
+		{value: "soon", err: true},
+	}
+
+	for _, test := range tests {
+		var timeout timeoutFlag
+		err := timeout.Set(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s': %v", test.value, err)
+		}
+		if time.Duration(timeout) != test.timeout {
+			t.Errorf("Timeout '%s' didn't match expected %s: %s", test.value, test.timeout, time.Duration(timeout))
+		}
+	}
+}
+
+func TestVerboseLogging(t *testing.T) {
+	defer slog.SetDefault(slog.Default())
+
+	host := serveHandshake(t, normalHandshake)
+	tests := []struct {
+		name        string
+		verbose     bool
+		veryVerbose bool
+		lines       []string
+		missing     []string
+	}{
+		{
+			name:    "Default",
+			missing: []string{"Scanning host", "Dialing"},
+		},
+		{
+			name:    "-v",
+			verbose: true,
+			lines:   []string{`msg="Scanning host" host=` + host + " attempt=1", `msg="Detected MySQL" host=` + host + " server_version=8.0.21"},
+			missing: []string{"Dialing"},
+		},
+		{
+			name:        "-vv",
+			veryVerbose: true,
+			lines:       []string{"msg=Dialing host=" + host, `msg="Read handshake" host=` + host + " bytes=78", `msg="Decoded handshake" host=` + host},
+		},
+	}
+
+	for _, test := range tests {
+		var logs bytes.Buffer
+		slog.SetDefault(newLogger(&logs, test.verbose, test.veryVerbose))
+
+		var out bytes.Buffer
+		if _, err := scanAll(context.Background(), &out, io.Discard, []string{host}, scanOptions{timeout: time.Second}, formatJSONL); err != nil {
+			t.Fatalf("Failed to scan targets '%s': %s", test.name, err)
+		}
+		if strings.Contains(out.String(), "msg=") {
+			t.Errorf("Logs were written to the output '%s': %s", test.name, out.String())
+		}
+		for _, line := range test.lines {
+			if !strings.Contains(logs.String(), line) {
+				t.Errorf("Logs missing '%s' for '%s':\n%s", line, test.name, logs.String())
+			}
+		}
+		for _, line := range test.missing {
+			if strings.Contains(logs.String(), line) {
+				t.Errorf("Logs shouldn't include '%s' for '%s':\n%s", line, test.name, logs.String())
+			}
+		}
+	}
+}
+
+func TestWriteVersion(t *testing.T) {
+	var out bytes.Buffer
+	if err := writeVersion(&out); err != nil {
+		t.Fatalf("Failed to write version: %s", err)
+	}
+	if !strings.HasPrefix(out.String(), "mysql-scan "+version+" (commit ") {
+		t.Errorf("Version output didn't match expected: %s", out.String())
+	}
+}
+
+func TestVersionFlag(t *testing.T) {
+	// The host is refused so a scan would exit non-zero
+	out, err := runMain(t, "-version", "-host", closedPort(t))
+	if err != nil {
+		t.Fatalf("Expected -version to exit cleanly: %s", err)
+	}
+	if !strings.HasPrefix(string(out), "mysql-scan "+version) || strings.Contains(string(out), "Detected MySQL") {
+		t.Errorf("Expected only the version to be printed: %s", out)
+	}
+}
+
+func TestOutputFile(t *testing.T) {
+	host := serveHandshake(t, normalHandshake)
+	path := filepath.Join(t.TempDir(), "results.csv")
+
+	// Something already in the file should be truncated
+	if err := os.WriteFile(path, []byte("old results\n"), 0644); err != nil {
+		t.Fatalf("Failed to write output file: %s", err)
+	}
+
+	out, err := runMain(t, "-host", host, "-format", formatCSV, "-o", path)
+	if err != nil {
+		t.Fatalf("Failed to scan: %s", err)
+	}
+	if len(out) != 0 {
+		t.Errorf("Expected nothing on stdout with -o: %s", out)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read output file: %s", err)
+	}
+	expected := strings.Join(csvHeader, ",") + "\n" + host + ",true,true,MySQL,8.0.21,true,caching_sha2_password\n"
+	if string(contents) != expected {
+		t.Errorf("Output file didn't match expected\ngot:  %q\nwant: %q", contents, expected)
+	}
+}
+
+func TestOutputFileGzip(t *testing.T) {
+	host := serveHandshake(t, normalHandshake)
+	hostFile := filepath.Join(t.TempDir(), "hosts.txt")
+	if err := os.WriteFile(hostFile, []byte(host+"\n"+closedPort(t)+"\n"), 0644); err != nil {
+		t.Fatalf("Failed to write host file: %s", err)
+	}
+
+	// Compressed either by the .gz extension or by -gzip
+	for _, args := range [][]string{{"-o", filepath.Join(t.TempDir(), "results.gz")}, {"-gzip", "-o", filepath.Join(t.TempDir(), "results")}} {
+		if _, err := runMain(t, append([]string{"-hostfile", hostFile, "-format", formatHosts}, args...)...); err != nil {
+			t.Fatalf("%v: failed to scan: %s", args, err)
+		}
+
+		f, err := os.Open(args[len(args)-1])
+		if err != nil {
+			t.Fatalf("%v: failed to open output file: %s", args, err)
+		}
+		defer f.Close()
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatalf("%v: output file isn't gzipped: %s", args, err)
+		}
+		contents, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("%v: failed to decompress output file: %s", args, err)
+		}
+		if string(contents) != host+"\n" {
+			t.Errorf("%v: output file didn't match expected\ngot:  %q\nwant: %q", args, contents, host+"\n")
+		}
+	}
+}
+
+func TestRunExitCodes(t *testing.T) {
+	defer func(host, output string, targets []string, checkTLS bool) {
+		scanHost, scanOutput, scanTargets, scanCheckTLS = host, output, targets, checkTLS
+	}(scanHost, scanOutput, scanTargets, scanCheckTLS)
+
+	// Clear CLIENT_SSL (0x0800) in capability_flags_1 at offset 25
+	noSSL := append([]byte{}, normalHandshake...)
+	noSSL[26] &^= 0x08
+
+	tests := []struct {
+		name     string
+		host     string
+		targets  []string
+		checkTLS bool
+		code     int
+	}{
+		{"detected", serveHandshake(t, normalHandshake), nil, false, exitDetected},
+		{"not mysql", serveHandshake(t, []byte("SSH-2.0-OpenSSH_8.9\r\n")), nil, false, exitNotMySQL},
+		{"refused", closedPort(t), nil, false, exitConnectFailed},
+		{"no ssl", serveHandshake(t, noSSL), nil, true, exitCheckFailed},
+		{"targets detected", "", []string{closedPort(t), serveHandshake(t, normalHandshake)}, false, exitDetected},
+		{"targets not mysql", "", []string{closedPort(t), serveHandshake(t, nil)}, false, exitNotMySQL},
+		{"targets refused", "", []string{closedPort(t), closedPort(t)}, false, exitConnectFailed},
+	}
+
+	for _, test := range tests {
+		scanHost, scanTargets, scanCheckTLS = test.host, test.targets, test.checkTLS
+		scanOutput = filepath.Join(t.TempDir(), "results")
+		if code := run(io.Discard); code != test.code {
+			t.Errorf("%s: exit code didn't match expected %d: %d", test.name, test.code, code)
+		}
+	}
+}
+
+func TestFailOnEOLExitCode(t *testing.T) {
+	// 5.7 went end of life in 2023, so this doesn't depend on today's date
+	eol := append([]byte{}, normalHandshake...)
+	copy(eol[5:], "5.7.44")
+	host := serveHandshake(t, eol)
+
+	if _, err := runMain(t, "-host", host); err != nil {
+		t.Errorf("Expected end of life to be ignored without -fail-on-eol: %s", err)
+	}
+	_, err := runMain(t, "-host", host, "-fail-on-eol")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitCheckFailed {
+		t.Errorf("Expected exit code %d for an end of life release: %v", exitCheckFailed, err)
+	}
+
+	// The check uses endOfLifeNow, 8.0 went end of life in April 2026
+	defer func(host, output string, failOnEOL bool, now func() time.Time) {
+		scanHost, scanOutput, scanFailOnEOL, endOfLifeNow = host, output, failOnEOL, now
+	}(scanHost, scanOutput, scanFailOnEOL, endOfLifeNow)
+	scanHost, scanOutput, scanFailOnEOL = serveHandshake(t, normalHandshake), filepath.Join(t.TempDir(), "results"), true
+	for _, test := range []struct {
+		now  time.Time
+		code int
+	}{
+		{time.Date(2026, time.April, 1, 0, 0, 0, 0, time.UTC), exitDetected},
+		{time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC), exitCheckFailed},
+	} {
+		endOfLifeNow = func() time.Time { return test.now }
+		if code := run(io.Discard); code != test.code {
+			t.Errorf("%s: exit code didn't match expected %d: %d", test.now.Format(time.DateOnly), test.code, code)
+		}
+	}
+}
+
+func TestReadTargetsExitCode(t *testing.T) {
+	// A line longer than bufio.Scanner allows after a good one, the good host is still reported
+	host := serveHandshake(t, normalHandshake)
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(), "MYSQL_SCAN_MAIN_ARGS=-hostfile\n-\n-format\n"+formatHosts)
+	cmd.Stdin = strings.NewReader(host + "\n" + strings.Repeat("x", 1<<17) + "\n")
+	out, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitUsage {
+		t.Errorf("Expected exit code %d for unreadable targets: %v", exitUsage, err)
+	}
+	if string(out) != host+"\n" {
+		t.Errorf("Expected the host read before the error to be reported: %q", out)
+	}
+}
+
+func TestUsageExitCode(t *testing.T) {
+	for _, args := range [][]string{{"-format", "xml"}, {"-no-such-flag"}, {"-auth", "root"}, {"-template", "{{.Host"}, {"-debug-decode", "-banner"}, {"-source-ip", "eth0"}, {"-tls-skip-verify"}, {"-seed", "1"}, {"-randomize", "-hostfile", "-"}, {"-dump-auth", "-banner"}, {"-srv", "example.com", "-ip-only"}} {
+		_, err := runMain(t, args...)
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || exitErr.ExitCode() != exitUsage {
+			t.Errorf("%v: expected exit code %d: %v", args, exitUsage, err)
+		}
+	}
+}
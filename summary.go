@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Clock releases are checked for end of life by, in the summary and for -fail-on-eol, replaced in tests so
+// the results don't change as releases reach end of life
+var endOfLifeNow = time.Now
+
+// ScanSummary accumulates counts over a bulk scan to quantify exposure across a fleet
+// Add is safe to call from the scan workers at once
+type ScanSummary struct {
+	mu sync.Mutex
+
+	// Total is the number of hosts scanned
+	Total int `json:"total"`
+
+	// Reachable is the number of hosts that accepted a connection, whether or not they run MySQL
+	Reachable int `json:"reachable"`
+
+	// MySQL is the number of hosts a handshake was decoded from
+	MySQL int `json:"mysql"`
+
+	// Errored is the number of hosts MySQL wasn't detected on for any reason
+	Errored int `json:"errored"`
+
+	// Closed is the number of hosts that accepted the connection then closed it without sending anything
+	Closed int `json:"closed"`
+
+	// NoTLS is the number of detected hosts that don't advertise SSL, banner only hosts aren't counted
+	NoTLS int `json:"no_tls"`
+
+	// EndOfLife is the number of detected hosts running a release series that has reached end of life
+	EndOfLife int `json:"end_of_life"`
+
+	// Unscanned is the number of targets left unscanned because the scan stopped early, from -count,
+	// -deadline, -run-timeout or an interrupt. Hosts still to be read from stdin can't be counted
+	Unscanned int `json:"unscanned"`
+
+	// DialErrors counts hosts that couldn't be connected to by mysqlscan.DialError category,
+	// refused means the host is up but the port is closed while a timeout could be down or filtered
+	DialErrors map[string]int `json:"dial_errors"`
+
+	// Flavors and Versions count the detected hosts by Flavor() and ServerVersion
+	Flavors  map[string]int `json:"flavors"`
+	Versions map[string]int `json:"versions"`
+
+	// VersionHistogram counts the detected hosts by flavor and parsed version, such as "MySQL 8.0.32", so
+	// builds of one release are grouped. A version that doesn't parse is counted as sent by the server
+	VersionHistogram map[string]int `json:"version_histogram"`
+}
+
+func newScanSummary() *ScanSummary {
+	return &ScanSummary{
+		DialErrors: map[string]int{},
+		Flavors:    map[string]int{},
+		Versions:   map[string]int{},
+
+		VersionHistogram: map[string]int{},
+	}
+}
+
+// Add the result of scanning one host to the summary
+func (s *ScanSummary) Add(result *mysqlscan.ScanResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.Total++
+
+	if result.Reachable() {
+		s.Reachable++
+	}
+	if result.Err != nil {
+		s.Errored++
+		if errors.Is(result.Err, mysqlscan.ErrorConnectionClosed) {
+			s.Closed++
+		}
+		var dialErr *mysqlscan.DialError
+		if errors.As(result.Err, &dialErr) {
+			s.DialErrors[dialErr.Category]++
+		}
+		return
+	}
+
+	s.MySQL++
+	if !result.MySQL.BannerOnly && !result.MySQL.SupportsTLS() {
+		s.NoTLS++
+	}
+	if result.MySQL.IsEndOfLifeAt(endOfLifeNow()) {
+		s.EndOfLife++
+	}
+	s.Flavors[result.MySQL.Flavor()]++
+	s.Versions[result.MySQL.ServerVersion]++
+	s.VersionHistogram[histogramVersion(result.MySQL)]++
+}
+
+// Key of a detected host in the version histogram, the flavor then the numeric version
+func histogramVersion(sql *mysqlscan.MySQLv10) string {
+	version, err := sql.Version()
+	if err != nil {
+		return sql.ServerVersion
+	}
+
+	return sql.Flavor() + " " + version.String()
+}
+
+// Write the summary as JSON for the JSON formats, YAML for yaml, otherwise as Prometheus style metrics
+// The text format follows the metrics with a table of the version histogram
+func (s *ScanSummary) Write(w io.Writer, format string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch format {
+	case formatJSON, formatJSONL, formatJSONArray:
+		return json.NewEncoder(w).Encode(s)
+	case formatYAML:
+		return writeYAML(w, s)
+	}
+
+	metrics := []struct {
+		name  string
+		value int
+	}{
+		{"mysqlscan_hosts_total", s.Total},
+		{"mysqlscan_hosts_reachable", s.Reachable},
+		{"mysqlscan_hosts_mysql", s.MySQL},
+		{"mysqlscan_hosts_errored", s.Errored},
+		{"mysqlscan_hosts_closed", s.Closed},
+		{"mysqlscan_hosts_no_tls", s.NoTLS},
+		{"mysqlscan_hosts_end_of_life", s.EndOfLife},
+		{"mysqlscan_hosts_unscanned", s.Unscanned},
+	}
+	for _, m := range metrics {
+		if _, err := fmt.Fprintf(w, "%s %d\n", m.name, m.value); err != nil {
+			return err
+		}
+	}
+
+	if err := writeLabeledMetric(w, "mysqlscan_hosts_dial_error", "category", s.DialErrors); err != nil {
+		return err
+	}
+	if err := writeLabeledMetric(w, "mysqlscan_hosts_flavor", "flavor", s.Flavors); err != nil {
+		return err
+	}
+	if err := writeLabeledMetric(w, "mysqlscan_hosts_version", "version", s.Versions); err != nil {
+		return err
+	}
+	if format != formatText {
+		return nil
+	}
+	return writeVersionHistogram(w, s.VersionHistogram)
+}
+
+// Write the version histogram as a table, the most common versions first
+// Each line is a comment so the output still parses as metrics
+func writeVersionHistogram(w io.Writer, counts map[string]int) error {
+	versions := make([]string, 0, len(counts))
+	width := 0
+	for version := range counts {
+		versions = append(versions, version)
+		width = max(width, len(version))
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		if counts[versions[i]] != counts[versions[j]] {
+			return counts[versions[i]] > counts[versions[j]]
+		}
+		return versions[i] < versions[j]
+	})
+
+	for _, version := range versions {
+		hosts := "hosts"
+		if counts[version] == 1 {
+			hosts = "host"
+		}
+		if _, err := fmt.Fprintf(w, "# %-*s %d %s\n", width, version, counts[version], hosts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write one metric line per label value, sorted so the output is stable between runs
+func writeLabeledMetric(w io.Writer, name, label string, counts map[string]int) error {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if _, err := fmt.Fprintf(w, "%s{%s=%q} %d\n", name, label, key, counts[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
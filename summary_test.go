@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+func TestScanSummary(t *testing.T) {
+	// MariaDB handshake is the capture with the version swapped for a same length MariaDB one
+	mariaDB := append([]byte{}, normalHandshake...)
+	copy(mariaDB[5:11], "10.6.1")
+	mariaDB = append(mariaDB[:11], append([]byte("-MariaDB"), mariaDB[11:]...)...)
+	mariaDB[0] += byte(len("-MariaDB"))
+
+	targets := []string{
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, normalHandshake),
+		serveHandshake(t, mariaDB),
+		serveHandshake(t, []byte("SSH-2.0-OpenSSH_8.9\r\n")),
+		serveHandshake(t, nil),
+		closedPort(t),
+		closedPort(t),
+	}
+
+	// After MySQL 8.0 reached end of life but before MariaDB 10.6 did
+	defer func(now func() time.Time) { endOfLifeNow = now }(endOfLifeNow)
+	endOfLifeNow = func() time.Time { return time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC) }
+
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, targets, scanOptions{concurrency: 3, timeout: time.Second}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+
+	expected := &ScanSummary{
+		Total:      7,
+		Reachable:  5,
+		MySQL:      3,
+		Errored:    4,
+		Closed:     1,
+		EndOfLife:  2,
+		DialErrors: map[string]int{"refused": 2},
+		Flavors:    map[string]int{"MySQL": 2, "MariaDB": 1},
+		Versions:   map[string]int{"8.0.21": 2, "10.6.1-MariaDB": 1},
+
+		VersionHistogram: map[string]int{"MySQL 8.0.21": 2, "MariaDB 10.6.1": 1},
+	}
+	if !reflect.DeepEqual(summary, expected) {
+		t.Errorf("Summary didn't match expected\ngot:  %+v\nwant: %+v", summary, expected)
+	}
+
+	var out bytes.Buffer
+	if err := summary.Write(&out, formatText); err != nil {
+		t.Fatalf("Failed to write summary: %s", err)
+	}
+	for _, line := range []string{"mysqlscan_hosts_total 7\n", "mysqlscan_hosts_closed 1\n", "mysqlscan_hosts_dial_error{category=\"refused\"} 2\n", "mysqlscan_hosts_flavor{flavor=\"MariaDB\"} 1\n"} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Summary output missing '%s':\n%s", line, out.String())
+		}
+	}
+}
+
+func TestScanSummaryVersionHistogram(t *testing.T) {
+	var targets []string
+	for _, version := range []string{"8.0.32", "8.0.32", "5.7.40", "8.0.32"} {
+		addr, stop := mysqlscan.StartFakeServer(version, mysqlscan.ClientProtocol41|mysqlscan.ClientSecureConnection)
+		t.Cleanup(stop)
+		targets = append(targets, addr)
+	}
+
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, targets, scanOptions{concurrency: 4, timeout: time.Second}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	expected := map[string]int{"MySQL 8.0.32": 3, "MySQL 5.7.40": 1}
+	if !reflect.DeepEqual(summary.VersionHistogram, expected) {
+		t.Errorf("Histogram didn't match expected: %v", summary.VersionHistogram)
+	}
+
+	// Most common first, padded into a table
+	var out bytes.Buffer
+	if err := summary.Write(&out, formatText); err != nil {
+		t.Fatalf("Failed to write summary: %s", err)
+	}
+	if !strings.HasSuffix(out.String(), "# MySQL 8.0.32 3 hosts\n# MySQL 5.7.40 1 host\n") {
+		t.Errorf("Histogram table didn't match expected:\n%s", out.String())
+	}
+
+	// JSON has the histogram as a map
+	out.Reset()
+	if err := summary.Write(&out, formatJSON); err != nil {
+		t.Fatalf("Failed to write summary: %s", err)
+	}
+	if !strings.Contains(out.String(), `"version_histogram":{"MySQL 5.7.40":1,"MySQL 8.0.32":3}`) {
+		t.Errorf("JSON summary missing the histogram: %s", out.String())
+	}
+
+	// Safe to add to from every worker at once
+	summary = newScanSummary()
+	sql := &mysqlscan.MySQLv10{ServerVersion: "8.0.32"}
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			summary.Add(&mysqlscan.ScanResult{Host: "10.0.0.1:3306", MySQL: sql})
+		}()
+	}
+	wg.Wait()
+	if summary.VersionHistogram["MySQL 8.0.32"] != 50 || summary.Total != 50 {
+		t.Errorf("Concurrent adds were lost: %+v", summary)
+	}
+}
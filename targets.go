@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Largest number of addresses a CIDR range is allowed to expand to (a /16 for IPv4)
+// Anything bigger would allocate a huge target list before a single host is probed
+const maxCIDRTargets = 1 << 16
+
+var (
+	ErrorRangeTooLarge = errors.New("CIDR range expands to too many hosts")
+	ErrorNotIP         = errors.New("Host isn't an IP address and -ip-only doesn't allow resolving it")
+	ErrorInvalidPorts  = errors.New("Ports must be a comma separated list of ports or ranges such as 3306,3307,33060 or 3306-3310")
+	ErrorNoSRVRecords  = errors.New("No _mysql._tcp SRV records found")
+
+	// ErrorReadTargets is returned when the targets couldn't be read, such as a read error on stdin
+	// or a line too long, as opposed to the results failing to be written
+	ErrorReadTargets = errors.New("Failed to read targets")
+)
+
+// Looks up SRV records, *net.Resolver implements it
+type srvResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Resolve the _mysql._tcp SRV records of domain into host:port targets
+// The resolver returns them sorted by priority and shuffled by weight, which is the order they're scanned in
+func resolveSRV(ctx context.Context, resolver srvResolver, domain string) ([]string, error) {
+	_, records, err := resolver.LookupSRV(ctx, "mysql", "tcp", domain)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrorNoSRVRecords
+	}
+
+	targets := make([]string, 0, len(records))
+	for _, srv := range records {
+		targets = append(targets, net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port))))
+	}
+
+	return targets, nil
+}
+
+// Expand a CIDR range such as 10.0.0.0/24 into a host:port target for every address in the range
+// Network and broadcast addresses are included since a host could still be listening on them
+func expandCIDR(cidr string, port int) ([]string, error) {
+	_, ipNet, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return nil, err
+	}
+
+	ones, bits := ipNet.Mask.Size()
+	if bits-ones > 16 || 1<<uint(bits-ones) > maxCIDRTargets {
+		return nil, ErrorRangeTooLarge
+	}
+
+	portStr := strconv.Itoa(port)
+	targets := make([]string, 0, 1<<uint(bits-ones))
+	for ip := ipNet.IP; ipNet.Contains(ip); ip = nextIP(ip) {
+		targets = append(targets, net.JoinHostPort(ip.String(), portStr))
+	}
+
+	return targets, nil
+}
+
+// Parse a port spec, a comma separated list of ports and inclusive ranges such as 3306,3310-3312
+// Ports are returned in the order given with duplicates removed
+func parsePorts(spec string) ([]int, error) {
+	var ports []int
+	seen := map[int]bool{}
+	for _, part := range strings.Split(spec, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		low, err := parsePort(first)
+		if err != nil {
+			return nil, err
+		}
+		high := low
+		if isRange {
+			if high, err = parsePort(last); err != nil {
+				return nil, err
+			}
+			if high < low {
+				return nil, ErrorInvalidPorts
+			}
+		}
+
+		for port := low; port <= high; port++ {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 1 || port > 65535 {
+		return 0, ErrorInvalidPorts
+	}
+
+	return port, nil
+}
+
+// Shuffle targets in place for -randomize so a range isn't swept in order, the same seed always gives the same order
+func shuffleTargets(targets []string, seed uint64) {
+	r := rand.New(rand.NewPCG(seed, seed))
+	r.Shuffle(len(targets), func(i, j int) {
+		targets[i], targets[j] = targets[j], targets[i]
+	})
+}
+
+// Expand host into a host:port target for every port, any port already on host is replaced
+func expandPorts(host string, ports []int) []string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+
+	targets := make([]string, 0, len(ports))
+	for _, port := range ports {
+		targets = append(targets, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+
+	return targets
+}
+
+// Check the host part of a host:port target is an IP address, so dialing it can't cause a DNS lookup
+func checkIPHost(target string) error {
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		return err
+	}
+	if net.ParseIP(host) == nil {
+		return ErrorNotIP
+	}
+
+	return nil
+}
+
+// Return a copy of ip incremented by one, wrapping back to zero after the last address
+func nextIP(ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	return next
+}
+
+// Read host:port targets from a file, one per line
+func readHostFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return readTargets(f)
+}
+
+// Read host:port targets one per line, ignoring blank lines and lines starting with #
+func readTargets(r io.Reader) ([]string, error) {
+	var targets []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if target, ok := targetLine(scanner.Text()); ok {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets, scanner.Err()
+}
+
+// Send host:port targets read one per line from r on jobs as soon as each line is read, the same lines are
+// skipped as readTargets
+// Returns at EOF, on a read error or once ctx is done, even while r has nothing to read
+func sendTargets(ctx context.Context, r io.Reader, jobs chan<- string) error {
+	// Lines are read in their own goroutine so an idle reader, such as stdin that nothing is written to,
+	// can't hold up ctx ending the feed. The goroutine is left blocked in Read until r has more or is closed
+	lines := make(chan string)
+	readErr := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		readErr <- scanner.Err()
+	}()
+
+	for {
+		var line string
+		var more bool
+		select {
+		case line, more = <-lines:
+		case <-ctx.Done():
+			return nil
+		}
+		if !more {
+			// Sent before lines is closed, unless the goroutine gave up because ctx was done
+			select {
+			case err := <-readErr:
+				return err
+			default:
+				return nil
+			}
+		}
+
+		target, ok := targetLine(line)
+		if !ok {
+			continue
+		}
+		select {
+		case jobs <- target:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// The target on a line of a host file, false for blank lines and comments
+func targetLine(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", false
+	}
+
+	return line, true
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestExpandCIDR(t *testing.T) {
+	tests := []struct {
+		name    string
+		cidr    string
+		targets []string
+		err     bool
+	}{
+		{
+			name: "IPv4 /30",
+			cidr: "192.168.1.4/30",
+			targets: []string{
+				"192.168.1.4:3306", "192.168.1.5:3306", "192.168.1.6:3306", "192.168.1.7:3306",
+			},
+		},
+		{
+			name:    "IPv4 /32",
+			cidr:    "10.0.0.1/32",
+			targets: []string{"10.0.0.1:3306"},
+		},
+		{
+			name:    "Host bits set",
+			cidr:    "10.0.0.1/31",
+			targets: []string{"10.0.0.0:3306", "10.0.0.1:3306"},
+		},
+		{
+			name:    "IPv6 /127",
+			cidr:    "2001:db8::/127",
+			targets: []string{"[2001:db8::]:3306", "[2001:db8::1]:3306"},
+		},
+		{
+			name: "Range too large",
+			cidr: "10.0.0.0/8",
+			err:  true,
+		},
+		{
+			name: "Not a CIDR",
+			cidr: "10.0.0.0/abc",
+			err:  true,
+		},
+	}
+
+	for _, test := range tests {
+		targets, err := expandCIDR(test.cidr, 3306)
+		if (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s': %v", test.name, err)
+		}
+		if !reflect.DeepEqual(targets, test.targets) {
+			t.Errorf("Expanded targets didn't match expected '%s': %v", test.name, targets)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec  string
+		ports []int
+		err   bool
+	}{
+		{spec: "3306", ports: []int{3306}},
+		{spec: "3306,3307,33060", ports: []int{3306, 3307, 33060}},
+		{spec: "3306-3310", ports: []int{3306, 3307, 3308, 3309, 3310}},
+		{spec: "33060, 3306-3308,3307", ports: []int{33060, 3306, 3307, 3308}},
+		{spec: "3310-3306", err: true},
+		{spec: "3306,", err: true},
+		{spec: "0", err: true},
+		{spec: "65536", err: true},
+		{spec: "mysql", err: true},
+	}
+
+	for _, test := range tests {
+		ports, err := parsePorts(test.spec)
+		if (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s': %v", test.spec, err)
+		}
+		if !reflect.DeepEqual(ports, test.ports) {
+			t.Errorf("Ports didn't match expected '%s': %v", test.spec, ports)
+		}
+	}
+}
+
+func TestExpandPorts(t *testing.T) {
+	tests := []struct {
+		host    string
+		targets []string
+	}{
+		{host: "10.0.0.5", targets: []string{"10.0.0.5:3306", "10.0.0.5:33060"}},
+		{host: "10.0.0.5:3307", targets: []string{"10.0.0.5:3306", "10.0.0.5:33060"}},
+		{host: "::1", targets: []string{"[::1]:3306", "[::1]:33060"}},
+		{host: "[::1]", targets: []string{"[::1]:3306", "[::1]:33060"}},
+	}
+
+	for _, test := range tests {
+		if targets := expandPorts(test.host, []int{3306, 33060}); !reflect.DeepEqual(targets, test.targets) {
+			t.Errorf("Expanded targets didn't match expected '%s': %v", test.host, targets)
+		}
+	}
+}
+
+func TestCheckIPHost(t *testing.T) {
+	tests := []struct {
+		host string
+		err  bool
+	}{
+		{host: "10.0.0.5:3306"},
+		{host: "[::1]:3306"},
+		{host: "db.example.com:3306", err: true},
+		{host: "localhost:3306", err: true},
+		{host: "10.0.0.5", err: true},
+	}
+
+	for _, test := range tests {
+		if err := checkIPHost(test.host); (err != nil) != test.err {
+			t.Errorf("Returned error didn't match expected '%s': %v", test.host, err)
+		}
+	}
+}
+
+func TestReadHostFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.txt")
+	contents := "# Database servers\n10.0.0.1:3306\n\n  10.0.0.2:3307  \n\t\n# db.example.com:3306\ndb.example.com:3306\n"
+	if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write host file: %s", err)
+	}
+
+	targets, err := readHostFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read host file: %s", err)
+	}
+
+	expected := []string{"10.0.0.1:3306", "10.0.0.2:3307", "db.example.com:3306"}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("Targets didn't match expected\ngot:  %v\nwant: %v", targets, expected)
+	}
+}
+
+// Resolver returning fixed SRV records for one domain
+type fakeSRVResolver struct {
+	domain  string
+	records []*net.SRV
+}
+
+func (r *fakeSRVResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if service != "mysql" || proto != "tcp" || name != r.domain {
+		return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+
+	return "_mysql._tcp." + name + ".", r.records, nil
+}
+
+func TestResolveSRV(t *testing.T) {
+	var records []*net.SRV
+	var expected []string
+	for i := range 2 {
+		host, port, _ := net.SplitHostPort(serveHandshake(t, normalHandshake))
+		portNum, _ := strconv.Atoi(port)
+		records = append(records, &net.SRV{Target: host + ".", Port: uint16(portNum), Priority: uint16(i)})
+		expected = append(expected, net.JoinHostPort(host, port))
+	}
+	resolver := &fakeSRVResolver{domain: "db.example.com", records: records}
+
+	targets, err := resolveSRV(context.Background(), resolver, "db.example.com")
+	if err != nil {
+		t.Fatalf("Failed to resolve SRV records: %s", err)
+	}
+	if !reflect.DeepEqual(targets, expected) {
+		t.Errorf("SRV targets didn't match expected %v: %v", expected, targets)
+	}
+
+	summary, err := scanAll(context.Background(), io.Discard, io.Discard, targets, scanOptions{concurrency: 2, timeout: time.Second}, formatText)
+	if err != nil {
+		t.Fatalf("Failed to scan targets: %s", err)
+	}
+	if summary.Total != 2 || summary.MySQL != 2 {
+		t.Errorf("Expected both SRV targets to be detected, got %+v", summary)
+	}
+
+	if _, err := resolveSRV(context.Background(), &fakeSRVResolver{domain: "empty.example.com"}, "empty.example.com"); !errors.Is(err, ErrorNoSRVRecords) {
+		t.Errorf("Expected ErrorNoSRVRecords, got: %v", err)
+	}
+	if _, err := resolveSRV(context.Background(), resolver, "other.example.com"); err == nil {
+		t.Errorf("Expected an error for a domain without SRV records")
+	}
+}
+
+func TestShuffleTargets(t *testing.T) {
+	targets, err := expandCIDR("10.0.0.0/24", 3306)
+	if err != nil {
+		t.Fatalf("Failed to expand range: %s", err)
+	}
+
+	first := append([]string{}, targets...)
+	shuffleTargets(first, 42)
+	second := append([]string{}, targets...)
+	shuffleTargets(second, 42)
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Same seed gave different orders")
+	}
+	if reflect.DeepEqual(first, targets) {
+		t.Errorf("Targets weren't shuffled")
+	}
+
+	// Every target is still there exactly once
+	seen := map[string]int{}
+	for _, target := range first {
+		seen[target]++
+	}
+	for _, target := range targets {
+		if seen[target] != 1 {
+			t.Errorf("Expected %s once in the shuffled targets, got %d", target, seen[target])
+		}
+	}
+
+	other := append([]string{}, targets...)
+	shuffleTargets(other, 43)
+	if reflect.DeepEqual(first, other) {
+		t.Errorf("Different seeds gave the same order")
+	}
+}
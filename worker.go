@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Delay before the first retry of a host, doubled for every retry after that
+var retryBackoff = 250 * time.Millisecond
+
+// Options for how each host is scanned
+type scanOptions struct {
+	// concurrency is the number of hosts scanned at once
+	concurrency int
+
+	// timeout for each attempt at a host
+	timeout time.Duration
+
+	// connectTimeout and readTimeout split the timeout between dialing and reading the handshake,
+	// either one left zero is the whole timeout. When both are zero the timeout covers the attempt
+	connectTimeout time.Duration
+	readTimeout    time.Duration
+
+	// retries is how many more attempts a host gets after a connect or read error
+	retries int
+
+	// dialer connects to each host, nil connects directly
+	dialer mysqlscan.ContextDialer
+
+	// banner only reads and decodes the server version, see mysqlscan.DetectMySQLBanner
+	banner bool
+
+	// ipOnly rejects hosts that aren't IP addresses before dialing so no DNS lookups are made
+	ipOnly bool
+
+	// tls upgrades each connection to TLS after the handshake to report the server's certificate
+	tls bool
+
+	// tlsSkipVerify accepts any certificate during the TLS upgrade, so self-signed servers are still reported
+	tlsSkipVerify bool
+
+	// forceDecode decodes handshakes with any protocol version, see mysqlscan.DecodeOptions
+	forceDecode bool
+
+	// maxBytes caps how many bytes are read from each host, 0 is the default for the handshake or banner
+	maxBytes int
+
+	// debugDecode writes a trace of each handshake's fields as they're decoded, nil doesn't trace
+	debugDecode *decodeDebugger
+
+	// dumpAuth is written a hex and ASCII dump of each detected server's auth data, nil doesn't dump
+	dumpAuth io.Writer
+
+	// rate is the most connections per second across every worker, including retries, 0 is unlimited
+	rate float64
+
+	// jitter is the most random delay added before each connection
+	jitter time.Duration
+
+	// progress counts each host as it finishes, nil when progress isn't reported
+	progress *progress
+
+	// onlyMySQL leaves hosts MySQL wasn't detected on out of the output, they're still in the summary
+	onlyMySQL bool
+
+	// count stops the scan once this many servers are detected, 0 scans every target
+	count int
+
+	// deadline aborts a bulk scan at that time, hosts in progress and any left are unscanned, zero has no deadline
+	deadline time.Time
+
+	// runTimeout aborts a bulk scan that long after it started the same as deadline, whichever comes first
+	runTimeout time.Duration
+
+	// template writes each detected host instead of the format's writer, nil uses the format
+	template *template.Template
+}
+
+// When a run that started at start has to stop, from the deadline or the run timeout, zero if neither is set
+func (o scanOptions) runDeadline(start time.Time) time.Time {
+	if o.runTimeout <= 0 {
+		return o.deadline
+	}
+	if timeout := start.Add(o.runTimeout); o.deadline.IsZero() || timeout.Before(o.deadline) {
+		return timeout
+	}
+
+	return o.deadline
+}
+
+// Scan each target received on jobs using a pool of workers, each host's result is sent on the returned channel
+// Results arrive in whatever order the hosts finish, the channel is closed once jobs is closed and drained
+// Cancelling ctx aborts the hosts in progress, their results are still sent
+func scanPool(ctx context.Context, jobs <-chan string, opts scanOptions) <-chan mysqlscan.ScanResult {
+	results := make(chan mysqlscan.ScanResult)
+	go func() {
+		defer close(results)
+		for result := range mysqlscan.BulkScanChannel(ctx, jobs, opts.bulkOptions()) {
+			opts.progress.add(result.Err == nil)
+			results <- result
+		}
+	}()
+
+	return results
+}
+
+// Detect MySQL on host with the retries, backoff and rate limit of a bulk scan, see mysqlscan.ScanHost
+func detectWithRetry(ctx context.Context, host string, opts scanOptions) (*mysqlscan.MySQLv10, error) {
+	return mysqlscan.ScanHost(ctx, host, opts.bulkOptions())
+}
+
+// Options for mysqlscan.BulkScan, each attempt at a host is made by detectAttempt
+func (o scanOptions) bulkOptions() mysqlscan.ScanOptions {
+	return mysqlscan.ScanOptions{
+		Concurrency: o.concurrency,
+		Retries:     o.retries,
+		Backoff:     retryBackoff,
+		Rate:        o.rate,
+		Jitter:      o.jitter,
+		Detect: func(ctx context.Context, host string) (*mysqlscan.MySQLv10, error) {
+			return detectAttempt(ctx, host, o)
+		},
+	}
+}
+
+// Make one attempt at detecting MySQL on host, bounded by opts.timeout
+// A host rejected by opts.ipOnly fails before anything is dialed and isn't retried
+func detectAttempt(ctx context.Context, host string, opts scanOptions) (*mysqlscan.MySQLv10, error) {
+	if opts.ipOnly {
+		if err := checkIPHost(host); err != nil {
+			return nil, fmt.Errorf("Failed to detect MySQL, invalid host '%s': %w", host, err)
+		}
+	}
+
+	attemptCtx, cancel := context.WithTimeout(ctx, opts.timeout)
+	dialer := opts.dialer
+	if opts.connectTimeout > 0 || opts.readTimeout > 0 {
+		// Each phase has its own deadline rather than one on the context, a run deadline on ctx can only bring
+		// the read deadline forward
+		cancel()
+		attemptCtx, cancel = context.WithCancel(ctx)
+		dialer = &mysqlscan.TimeoutDialer{
+			Forward:        opts.dialer,
+			ConnectTimeout: cmp.Or(opts.connectTimeout, opts.timeout),
+			ReadTimeout:    cmp.Or(opts.readTimeout, opts.timeout),
+		}
+	}
+	defer cancel()
+	decodeOpts := mysqlscan.DecodeOptions{AcceptAnyProtocolVersion: opts.forceDecode, MaxBytes: opts.maxBytes}
+	var flushTrace func()
+	if opts.debugDecode != nil {
+		decodeOpts.Trace, flushTrace = opts.debugDecode.trace(host)
+	}
+	detect := mysqlscan.DetectMySQLDialer
+	if opts.banner {
+		detect = func(ctx context.Context, dialer mysqlscan.ContextDialer, host string) (*mysqlscan.MySQLv10, error) {
+			return mysqlscan.DetectMySQLBannerOptions(ctx, dialer, host, decodeOpts)
+		}
+	} else if opts.tls {
+		detect = func(ctx context.Context, dialer mysqlscan.ContextDialer, host string) (*mysqlscan.MySQLv10, error) {
+			return detectTLS(ctx, dialer, host, opts.tlsSkipVerify)
+		}
+	} else if opts.forceDecode || opts.maxBytes > 0 || opts.debugDecode != nil {
+		detect = func(ctx context.Context, dialer mysqlscan.ContextDialer, host string) (*mysqlscan.MySQLv10, error) {
+			return mysqlscan.DetectMySQLOptions(ctx, dialer, host, decodeOpts)
+		}
+	}
+	sql, err := detect(attemptCtx, dialer, host)
+	if flushTrace != nil {
+		flushTrace()
+	}
+	if err == nil && opts.dumpAuth != nil && !sql.BannerOnly {
+		io.WriteString(opts.dumpAuth, authDump(host, sql))
+	}
+
+	return sql, err
+}
+
+// Detect MySQL on host then upgrade the connection to TLS, see mysqlscan.DetectMySQLTLS
+// A server without TLS or that fails the TLS handshake is still detected, just without its TLS info
+// The certificate is verified against the system roots unless skipVerify is set
+func detectTLS(ctx context.Context, dialer mysqlscan.ContextDialer, host string, skipVerify bool) (*mysqlscan.MySQLv10, error) {
+	var config *tls.Config
+	if skipVerify {
+		config = &tls.Config{InsecureSkipVerify: true}
+	}
+	sql, err := mysqlscan.DetectMySQLTLS(ctx, dialer, host, config)
+	if err != nil && sql != nil {
+		slog.Warn("TLS upgrade failed", "host", host, "error", err)
+		return sql, nil
+	}
+
+	return sql, err
+}
+
+// Detect MySQL on the UNIX socket at path, timeout applies to connecting and then again to reading the handshake
+// Dial errors wrap mysqlscan.ErrorConnect the same as for a host
+func detectSocket(path string, timeout time.Duration) (*mysqlscan.MySQLv10, error) {
+	slog.Info("Scanning socket", "path", path)
+	conn, err := net.DialTimeout("unix", path, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", mysqlscan.ErrorConnect, err)
+	}
+	defer conn.Close()
+
+	return mysqlscan.DetectMySQLConn(conn, timeout)
+}
+
+// Dump of sql's auth data for -dump-auth, a line saying whose it is then the bytes laid out like hexdump -C
+// Returned as one string so dumps of hosts scanned at the same time can be written without interleaving
+func authDump(host string, sql *mysqlscan.MySQLv10) string {
+	return fmt.Sprintf("%s: auth data from %s, %d bytes\n%s", host, cmp.Or(sql.AuthPlugin, "no auth plugin"), len(sql.AuthData), hex.Dump(sql.AuthData))
+}
+
+// Writes the decode trace of each host for -debug-decode, a host's trace is written in one go so
+// the traces of hosts scanned at the same time don't interleave
+type decodeDebugger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Returns a trace function for decoding host's handshake and a flush function to write the trace once decoding is done
+func (d *decodeDebugger) trace(host string) (func(mysqlscan.DecodeStep), func()) {
+	var buf bytes.Buffer
+	trace := func(step mysqlscan.DecodeStep) {
+		if step.Err != nil {
+			fmt.Fprintf(&buf, "%s: decode failed at offset %d: %s\n", host, step.Offset, step.Err)
+			return
+		}
+		fmt.Fprintf(&buf, "%s: offset %d %s raw=%x value=%v\n", host, step.Offset, step.Field, step.Raw, step.Value)
+	}
+	flush := func() {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+		d.w.Write(buf.Bytes())
+		buf.Reset()
+	}
+
+	return trace, flush
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+func TestDetectWithRetry(t *testing.T) {
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Millisecond
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+
+	// Drop the first connection without sending anything, then behave like MySQL
+	var accepted int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			if atomic.AddInt32(&accepted, 1) > 1 {
+				conn.Write(normalHandshake)
+			}
+			conn.Close()
+		}
+	}()
+
+	host := ln.Addr().String()
+	if _, err := detectWithRetry(context.Background(), host, scanOptions{timeout: time.Second}); err == nil {
+		t.Fatalf("Expected the dropped connection to fail without retries")
+	}
+
+	atomic.StoreInt32(&accepted, 0)
+	sql, err := detectWithRetry(context.Background(), host, scanOptions{timeout: time.Second, retries: 1})
+	if err != nil {
+		t.Fatalf("Expected one retry to recover: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Server version didn't match expected: %s", sql.ServerVersion)
+	}
+	if n := atomic.LoadInt32(&accepted); n != 2 {
+		t.Errorf("Expected 2 connections, got %d", n)
+	}
+
+	// Not MySQL is a definite answer, so the retries shouldn't be used
+	notMySQL := serveHandshake(t, []byte("SSH-2.0-OpenSSH_8.9\r\n"))
+	start := time.Now()
+	retryBackoff = time.Second
+	if _, err := detectWithRetry(context.Background(), notMySQL, scanOptions{timeout: time.Second, retries: 3}); err == nil {
+		t.Errorf("Expected an error for a non MySQL server")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Non MySQL server was retried")
+	}
+}
+
+// Connection whose first read fails with a reset, like a balancer dropping the first connection of a flow
+type resetConn struct {
+	net.Conn
+}
+
+func (c *resetConn) Read([]byte) (int, error) {
+	return 0, &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+}
+
+// Dialer that resets the first connection then connects to the real address
+type resetDialer struct {
+	dialed int
+}
+
+func (d *resetDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.dialed++
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil || d.dialed > 1 {
+		return conn, err
+	}
+
+	return &resetConn{Conn: conn}, nil
+}
+
+func TestDetectWithRetryReset(t *testing.T) {
+	// Long enough that waiting for it would time the test out
+	defer func(backoff time.Duration) { retryBackoff = backoff }(retryBackoff)
+	retryBackoff = time.Hour
+
+	addr := serveHandshake(t, normalHandshake)
+	dialer := &resetDialer{}
+	sql, err := detectWithRetry(context.Background(), addr, scanOptions{timeout: time.Second, retries: 1, dialer: dialer})
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL after the reset: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" || dialer.dialed != 2 {
+		t.Errorf("Expected a retry after the reset, dialed %d times: %v", dialer.dialed, sql)
+	}
+
+	// Without retries the reset is reported
+	_, err = detectWithRetry(context.Background(), addr, scanOptions{timeout: time.Second, dialer: &resetDialer{}})
+	if !errors.Is(err, mysqlscan.ErrorConnectionReset) || !errors.Is(err, mysqlscan.ErrorRead) {
+		t.Errorf("Expected ErrorConnectionReset, got: %v", err)
+	}
+}
+
+func TestDetectWithRetryIPOnly(t *testing.T) {
+	host := serveHandshake(t, normalHandshake)
+	_, port, _ := net.SplitHostPort(host)
+
+	if _, err := detectWithRetry(context.Background(), host, scanOptions{timeout: time.Second, ipOnly: true}); err != nil {
+		t.Errorf("Expected an IP address to be scanned with ipOnly: %s", err)
+	}
+
+	// localhost would resolve and connect, so an error means it was never dialed
+	_, err := detectWithRetry(context.Background(), net.JoinHostPort("localhost", port), scanOptions{timeout: time.Second, ipOnly: true})
+	if !errors.Is(err, ErrorNotIP) {
+		t.Errorf("Expected ErrorNotIP for a hostname with ipOnly, got: %v", err)
+	}
+}
+
+func TestDetectWithRetryReadTimeout(t *testing.T) {
+	// Accepts straight away but never sends the handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		<-done
+	}()
+
+	// The whole timeout is far longer, only the read timeout can end the attempt this quickly
+	opts := scanOptions{timeout: 10 * time.Second, readTimeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err = detectWithRetry(context.Background(), ln.Addr().String(), opts)
+	if !errors.Is(err, mysqlscan.ErrorRead) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Expected the read to time out, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Read timeout took %s, expected about %s", elapsed, opts.readTimeout)
+	}
+}
+
+func TestDetectWithRetryTLSNotSupported(t *testing.T) {
+	// Clear CLIENT_SSL (0x0800) in capability_flags_1 at offset 25
+	noSSL := append([]byte{}, normalHandshake...)
+	noSSL[26] &^= 0x08
+
+	// Still MySQL, there just isn't any TLS to report
+	sql, err := detectWithRetry(context.Background(), serveHandshake(t, noSSL), scanOptions{timeout: time.Second, tls: true})
+	if err != nil || sql.TLS != nil {
+		t.Errorf("Expected MySQL detected without TLS, got %v: %v", sql, err)
+	}
+}
+
+// Fake MySQL server that upgrades to TLS with a freshly generated self-signed certificate after the SSL request
+func serveSelfSignedTLS(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "self-signed"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %s", err)
+	}
+	cert := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				conn.Write(normalHandshake)
+
+				// 4 byte header then the 32 byte SSL request
+				if _, err := io.ReadFull(conn, make([]byte, 4+32)); err != nil {
+					return
+				}
+				tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+				tlsConn.Handshake()
+				tlsConn.Read(make([]byte, 1))
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func TestDetectWithRetryTLSSkipVerify(t *testing.T) {
+	addr := serveSelfSignedTLS(t)
+
+	// Verification is on by default, so the self-signed certificate fails and there's no TLS to report
+	sql, err := detectWithRetry(context.Background(), addr, scanOptions{timeout: time.Second, tls: true})
+	if err != nil || sql.TLS != nil {
+		t.Errorf("Expected MySQL detected without TLS while verifying, got %v: %v", sql, err)
+	}
+
+	sql, err = detectWithRetry(context.Background(), addr, scanOptions{timeout: time.Second, tls: true, tlsSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	if sql.TLS == nil || sql.TLS.Subject != "CN=self-signed" {
+		t.Errorf("Expected the self-signed certificate to be reported, got %v", sql.TLS)
+	}
+}
+
+func TestDetectSocket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mysqld.sock")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("Failed to listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		conn.Write(normalHandshake)
+		conn.Close()
+	}()
+
+	sql, err := detectSocket(path, time.Second)
+	if err != nil {
+		t.Fatalf("Failed to detect MySQL on the socket: %s", err)
+	}
+	if sql.ServerVersion != "8.0.21" {
+		t.Errorf("Socket handshake didn't match expected: %s", sql.String())
+	}
+
+	// Nothing listening is a connect error like a refused port
+	if _, err := detectSocket(filepath.Join(t.TempDir(), "missing.sock"), time.Second); !errors.Is(err, mysqlscan.ErrorConnect) {
+		t.Errorf("Expected ErrorConnect for a missing socket, got: %v", err)
+	}
+}
+
+func TestDetectWithRetryDebugDecode(t *testing.T) {
+	addr := serveHandshake(t, normalHandshake)
+
+	var out bytes.Buffer
+	opts := scanOptions{timeout: time.Second, debugDecode: &decodeDebugger{w: &out}}
+	if _, err := detectWithRetry(context.Background(), addr, opts); err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	for _, line := range []string{
+		addr + ": offset 0 header raw=4a000000 value=74\n",
+		addr + ": offset 5 server_version raw=382e302e323100 value=8.0.21\n",
+		addr + ": offset 12 connection_id raw=10000000 value=16\n",
+		addr + ": offset 56 auth_plugin_name raw=" + hex.EncodeToString([]byte("caching_sha2_password\x00")) + " value=caching_sha2_password\n",
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("Expected trace line %q in:\n%s", line, out.String())
+		}
+	}
+
+	// A server that isn't MySQL reports where decoding gave up
+	out.Reset()
+	addr = serveHandshake(t, []byte{0x05, 0x00, 0x00, 0x00, 0x0a, 'b', 'a', 'd', '!', '!'})
+	if _, err := detectWithRetry(context.Background(), addr, opts); err == nil {
+		t.Fatalf("Expected an error decoding a truncated handshake")
+	}
+	if !strings.Contains(out.String(), addr+": decode failed at offset 5: ") {
+		t.Errorf("Expected the failing offset in:\n%s", out.String())
+	}
+}
+
+func TestAuthDump(t *testing.T) {
+	sql := &mysqlscan.MySQLv10{AuthPlugin: "caching_sha2_password", AuthData: []byte("8cz{^\x07j9E85HP\x01\x02\x03\x04\x05\x06\x07")}
+	expected := "10.0.0.1:3306: auth data from caching_sha2_password, 20 bytes\n" +
+		"00000000  38 63 7a 7b 5e 07 6a 39  45 38 35 48 50 01 02 03  |8cz{^.j9E85HP...|\n" +
+		"00000010  04 05 06 07                                       |....|\n"
+	if dump := authDump("10.0.0.1:3306", sql); dump != expected {
+		t.Errorf("Dump didn't match expected:\n%s\nwant:\n%s", dump, expected)
+	}
+
+	// Written for each detected host
+	var out bytes.Buffer
+	addr := serveHandshake(t, normalHandshake)
+	if _, err := detectWithRetry(context.Background(), addr, scanOptions{timeout: time.Second, dumpAuth: &out}); err != nil {
+		t.Fatalf("Failed to detect MySQL: %s", err)
+	}
+	if !strings.HasPrefix(out.String(), addr+": auth data from caching_sha2_password, 20 bytes\n00000000  38 63 7a 7b 5e 07 6a 39") {
+		t.Errorf("Dump of the detected host's auth data didn't match expected:\n%s", out.String())
+	}
+}
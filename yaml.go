@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Marshal v as a YAML block document by way of its JSON encoding, so the YAML has exactly the fields, names and
+// order of the JSON output, including what MarshalJSON methods do such as writing AuthData as hex
+// Only the standard library is used, strings are always double quoted and numbers are written as JSON wrote them
+func marshalYAML(v any) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	tree, err := decodeYAMLTree(dec)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	switch tree := tree.(type) {
+	case yamlMap:
+		writeYAMLMap(&buf, tree, 0)
+	case []any:
+		writeYAMLList(&buf, tree, 0)
+	default:
+		buf.WriteString(yamlScalar(tree) + "\n")
+	}
+	if buf.Len() == 0 {
+		buf.WriteString("{}\n")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Write v to w as a YAML document, see marshalYAML
+func writeYAML(w io.Writer, v any) error {
+	data, err := marshalYAML(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// JSON object with its fields kept in order, decoding into a Go map would sort them
+type yamlMap []yamlField
+
+type yamlField struct {
+	key   string
+	value any
+}
+
+// Decode the next JSON value from dec into a yamlMap, []any or scalar token
+func decodeYAMLTree(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		m := yamlMap{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeYAMLTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			m = append(m, yamlField{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return m, err
+	case json.Delim('['):
+		list := []any{}
+		for dec.More() {
+			item, err := decodeYAMLTree(dec)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		_, err := dec.Token()
+		return list, err
+	}
+
+	return tok, nil
+}
+
+// Write each field of m on its own line at indent, nested maps and lists go on the following lines
+func writeYAMLMap(buf *bytes.Buffer, m yamlMap, indent int) {
+	for _, field := range m {
+		buf.WriteString(strings.Repeat(" ", indent) + yamlKey(field.key) + ":")
+		writeYAMLValue(buf, field.value, indent+2)
+	}
+}
+
+// Write each item of list on its own "-" line at indent
+func writeYAMLList(buf *bytes.Buffer, list []any, indent int) {
+	for _, item := range list {
+		buf.WriteString(strings.Repeat(" ", indent) + "-")
+		writeYAMLValue(buf, item, indent+2)
+	}
+}
+
+// Write the value following a key or list dash, empty maps and lists are written inline as {} and []
+func writeYAMLValue(buf *bytes.Buffer, v any, indent int) {
+	switch v := v.(type) {
+	case yamlMap:
+		if len(v) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAMLMap(buf, v, indent)
+	case []any:
+		if len(v) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAMLList(buf, v, indent)
+	default:
+		buf.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+// Keys that are a lowercase letter then letters, digits and underscores, like every JSON field name, are written plain
+// Anything else, such as a server version counted in the summary, is quoted so it can't be read as a number or bool
+func yamlKey(key string) string {
+	switch key {
+	case "", "true", "false", "null", "yes", "no", "on", "off", "y", "n":
+		return strconv.Quote(key)
+	}
+	for i, r := range key {
+		if (r < 'a' || r > 'z') && (i == 0 || (r < '0' || r > '9') && r != '_') {
+			return strconv.Quote(key)
+		}
+	}
+
+	return key
+}
+
+// YAML form of a JSON scalar token, Go's quoting only uses escapes that YAML double quoted strings also have
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	}
+
+	return "null"
+}
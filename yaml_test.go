@@ -0,0 +1,134 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/JakobGreen/mysql-scan/mysqlscan"
+)
+
+// Parse the subset of YAML that marshalYAML writes back into the values encoding/json would decode
+func unmarshalTestYAML(t *testing.T, data string) any {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(data, "\n"), "\n")
+
+	scalar := func(s string) any {
+		switch s {
+		case "{}":
+			return map[string]any{}
+		case "[]":
+			return []any{}
+		case "null":
+			return nil
+		case "true", "false":
+			return s == "true"
+		}
+		if strings.HasPrefix(s, `"`) {
+			unquoted, err := strconv.Unquote(s)
+			if err != nil {
+				t.Fatalf("Invalid quoted string %s: %s", s, err)
+			}
+			return unquoted
+		}
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			t.Fatalf("Invalid scalar %s: %s", s, err)
+		}
+		return n
+	}
+
+	// Parse the lines at indent starting from i, returning the value and the line after it
+	var parse func(i, indent int) (any, int)
+	parse = func(i, indent int) (any, int) {
+		prefix := strings.Repeat(" ", indent)
+		if strings.HasPrefix(lines[i], prefix+"-") {
+			list := []any{}
+			for i < len(lines) && strings.HasPrefix(lines[i], prefix+"-") {
+				if rest := strings.TrimPrefix(lines[i], prefix+"-"); rest != "" {
+					list = append(list, scalar(strings.TrimPrefix(rest, " ")))
+					i++
+					continue
+				}
+				var item any
+				item, i = parse(i+1, indent+2)
+				list = append(list, item)
+			}
+			return list, i
+		}
+
+		m := map[string]any{}
+		for i < len(lines) && strings.HasPrefix(lines[i], prefix) && lines[i][indent] != ' ' {
+			key, rest, ok := strings.Cut(lines[i][indent:], ":")
+			if !ok {
+				t.Fatalf("Line isn't a key: %s", lines[i])
+			}
+			if strings.HasPrefix(key, `"`) {
+				key = scalar(key).(string)
+			}
+			if rest != "" {
+				m[key] = scalar(strings.TrimPrefix(rest, " "))
+				i++
+				continue
+			}
+			m[key], i = parse(i+1, indent+2)
+		}
+		return m, i
+	}
+
+	v, _ := parse(0, 0)
+	return v
+}
+
+func TestMarshalYAML(t *testing.T) {
+	sql := mysqlscan.MySQLv10{}
+	if err := sql.Decode(normalHandshake); err != nil {
+		t.Fatalf("Failed to decode handshake: %s", err)
+	}
+	summary := newScanSummary()
+	summary.Add(&mysqlscan.ScanResult{Host: "10.0.0.1:3306", MySQL: &sql})
+
+	tests := []struct {
+		name string
+		v    any
+	}{
+		{name: "Handshake", v: &sql},
+		{name: "Result", v: &mysqlscan.ScanResult{Host: "10.0.0.1:3306", MySQL: &sql, Duration: 2 * time.Millisecond}},
+		{name: "Failed result", v: &mysqlscan.ScanResult{Host: "10.0.0.2:3306", Err: fmt.Errorf("%w: %w", mysqlscan.ErrorConnect, &mysqlscan.DialError{Category: mysqlscan.DialRefused})}},
+		{name: "Summary", v: summary},
+	}
+
+	for _, test := range tests {
+		out, err := marshalYAML(test.v)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal YAML: %s", test.name, err)
+		}
+		data, err := json.Marshal(test.v)
+		if err != nil {
+			t.Fatalf("%s: failed to marshal JSON: %s", test.name, err)
+		}
+		var expected any
+		if err := json.Unmarshal(data, &expected); err != nil {
+			t.Fatalf("%s: failed to unmarshal JSON: %s", test.name, err)
+		}
+
+		// Every field should come back with the same value as the JSON output
+		if actual := unmarshalTestYAML(t, string(out)); !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%s: YAML didn't match the JSON output\n%s\n%s", test.name, out, data)
+		}
+	}
+
+	out, err := marshalYAML(&sql)
+	if err != nil {
+		t.Fatalf("Failed to marshal YAML: %s", err)
+	}
+	for _, line := range []string{"protocol_version: 10\n", `server_version: "8.0.21"` + "\n", `auth_data: "` + sql.AuthDataHex() + `"` + "\n"} {
+		if !strings.Contains(string(out), line) {
+			t.Errorf("YAML missing '%s':\n%s", line, out)
+		}
+	}
+}